@@ -0,0 +1,23 @@
+package echoserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// requireAdmin returns a middleware that only lets through requests carrying
+// the configured admin token as an "Authorization: Bearer" header.
+func (cr *controller) requireAdmin() echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			token := cr.opts.AdminToken
+			return token != "" && subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+		},
+		ErrorHandler: func(err error, c echo.Context) error {
+			return echo.NewHTTPError(http.StatusUnauthorized, "admin authorization required")
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultTenantQueryTimeout = 5 * time.Second
+	aggregateConcurrency      = 4
+)
+
+// forEachTenant runs query for every tenant, at most [aggregateConcurrency]
+// at once and each within [Options.TenantQueryTimeout], so one slow tenant
+// cannot hold up a platform-wide report. It returns the client-facing reason
+// of each tenant whose query failed.
+func (cr *controller) forEachTenant(ctx context.Context, query func(ctx context.Context, tenantID string) error) (map[string]string, error) {
+	var tenants []string
+	if err := cr.db.WithContext(ctx).Model(&models.Tenant{}).Scopes(onboardedTenants).Pluck("schema_name", &tenants).Error; err != nil {
+		return nil, err
+	}
+	timeout := cr.opts.TenantQueryTimeout
+	if timeout <= 0 {
+		timeout = defaultTenantQueryTimeout
+	}
+
+	var (
+		mu     sync.Mutex
+		failed = make(map[string]string)
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, aggregateConcurrency)
+	)
+	for _, tenant := range tenants {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			tctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			err := query(tctx, tenant)
+			if err == nil {
+				return
+			}
+			log.Printf("Query of tenant %q failed: %v", tenant, err)
+			reason := categoryInfo[classifyError(err)].message
+			if errors.Is(err, context.DeadlineExceeded) {
+				reason = "query timed out"
+			}
+			mu.Lock()
+			failed[tenant] = reason
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return failed, ctx.Err()
+}
+
+// bookStatsHandler counts the books of every tenant. Tenants whose count
+// fails are reported alongside the others' counts rather than failing the
+// whole report.
+func (cr *controller) bookStatsHandler(c echo.Context) error {
+	var (
+		mu  sync.Mutex
+		res = models.BookStatsResponse{Books: make(map[string]int64)}
+	)
+	failed, err := cr.forEachTenant(c.Request().Context(), func(ctx context.Context, tenantID string) error {
+		db, err := cr.tenantDB(ctx, tenantID)
+		if err != nil {
+			return err
+		}
+		var n int64
+		if err = db.Table(models.TableNameBook).Scopes(scopes.WithTenantSchema(tenantID)).
+			Where("deleted_at IS NULL").Count(&n).Error; err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		res.Books[tenantID] = n
+		res.Total += n
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		res.Failed = failed
+	}
+	return respond(c, http.StatusOK, res)
+}
@@ -0,0 +1,79 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// degradedResolver, once broken, hangs resolving tenant2 until its context
+// is done and fails resolving tenant3.
+type degradedResolver struct {
+	TenantResolver
+	broken atomic.Bool
+}
+
+func (r *degradedResolver) Resolve(ctx context.Context, tenantID string) (*multitenancy.DB, error) {
+	if r.broken.Load() {
+		switch tenantID {
+		case "tenant2":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "tenant3":
+			return nil, errors.New("connection refused")
+		}
+	}
+	return r.TenantResolver.Resolve(ctx, tenantID)
+}
+
+func TestBookStatsPartialResults(t *testing.T) {
+	const token = "secret"
+	db := newSQLiteDB(t)
+	r := &degradedResolver{TenantResolver: SchemaResolver{DB: db}}
+	_, e := newServer(db, WithAdminToken(token), WithTenantResolver(r), WithTenantQueryTimeout(50*time.Millisecond))
+	for i, host := range []string{"tenant1.example.com", "tenant2.example.com", "tenant3.example.com", "tenant4.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		for range i + 1 {
+			rr := serve(e, http.MethodPost, "/books", host, `{"name": "Book", "author": "Author"}`)
+			require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+
+	stats := func() models.BookStatsResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/admin/books/stats", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		return decode[models.BookStatsResponse](t, rr)
+	}
+
+	assert.Equal(t, models.BookStatsResponse{
+		Books: map[string]int64{"tenant1": 1, "tenant2": 2, "tenant3": 3, "tenant4": 4},
+		Total: 10,
+	}, stats())
+
+	r.broken.Store(true)
+	start := time.Now()
+	assert.Equal(t, models.BookStatsResponse{
+		Books: map[string]int64{"tenant1": 1, "tenant4": 4},
+		Total: 5,
+		Failed: map[string]string{
+			"tenant2": "query timed out",
+			"tenant3": "internal server error",
+		},
+	}, stats(), "the healthy tenants are still reported")
+	assert.Less(t, time.Since(start), 5*time.Second, "the slow tenant is cut off")
+}
@@ -0,0 +1,88 @@
+package echoserver
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Audited actions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Audited entity types.
+const (
+	AuditTenant = "tenant"
+	AuditBook   = "book"
+)
+
+// AuditEvent records a committed mutation.
+type AuditEvent struct {
+	RequestID string    // RequestID identifies the request making the change.
+	Tenant    string    // Tenant is the schema of the tenant acting or, for tenant mutations, affected.
+	Action    string    // Action is one of AuditCreate, AuditUpdate and AuditDelete.
+	Entity    string    // Entity is one of AuditTenant and AuditBook.
+	EntityID  string    // EntityID identifies the entity, or the comma-separated entities of a bulk change.
+	Time      time.Time // Time is when the change was committed.
+}
+
+// AuditLogger records audit events to a sink.
+type AuditLogger interface {
+	Audit(ctx context.Context, event AuditEvent) error
+}
+
+// SlogAuditLogger is an [AuditLogger] writing each event as a structured log
+// entry.
+type SlogAuditLogger struct {
+	Logger *slog.Logger
+}
+
+var _ AuditLogger = SlogAuditLogger{}
+
+// Audit implements [AuditLogger].
+func (l SlogAuditLogger) Audit(ctx context.Context, event AuditEvent) error {
+	l.Logger.LogAttrs(ctx, slog.LevelInfo, "Audit",
+		slog.String("request_id", event.RequestID),
+		slog.String("tenant", event.Tenant),
+		slog.String("action", event.Action),
+		slog.String("entity", event.Entity),
+		slog.String("entity_id", event.EntityID),
+		slog.Time("time", event.Time),
+	)
+	return nil
+}
+
+// auditor returns the audit logger, defaulting to structured logs.
+func (cr *controller) auditor() AuditLogger {
+	if cr.opts.Auditor != nil {
+		return cr.opts.Auditor
+	}
+	return SlogAuditLogger{Logger: cr.logger()}
+}
+
+// audit records a committed mutation made by the request of ctx. The
+// mutation has already succeeded, so a failure to record it is logged rather
+// than returned.
+func (cr *controller) audit(ctx context.Context, tenant, action, entity, entityID string) {
+	event := AuditEvent{
+		RequestID: requestIDFromContext(ctx),
+		Tenant:    tenant,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		Time:      time.Now().UTC(),
+	}
+	if err := cr.auditor().Audit(ctx, event); err != nil {
+		cr.logger().ErrorContext(ctx, "Failed to write audit event",
+			"request_id", event.RequestID,
+			"tenant", event.Tenant,
+			"action", event.Action,
+			"entity", event.Entity,
+			"entity_id", event.EntityID,
+			"error", err,
+		)
+	}
+}
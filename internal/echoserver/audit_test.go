@@ -0,0 +1,99 @@
+package echoserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAuditor records audit events, optionally failing to.
+type recordingAuditor struct {
+	mu     sync.Mutex
+	events []AuditEvent
+	err    error
+}
+
+func (a *recordingAuditor) Audit(_ context.Context, event AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+	a.events = append(a.events, event)
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	a := &recordingAuditor{}
+	_, e := newSQLiteServer(t, WithAuditLogger(a))
+	const host = "tenant1.example.com"
+	send := func(method, path, host, body string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRequestID, "req-"+method)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		require.Less(t, rr.Code, 300, rr.Body.String())
+	}
+	send(http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	send(http.MethodPost, "/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+	send(http.MethodPost, "/books", host, `{"name": "Emma", "author": "Jane Austen"}`)
+	send(http.MethodPost, "/books", host, `{"name": "Ulysses", "author": "James Joyce"}`)
+	send(http.MethodPut, "/books/1", host, `{"name": "Dune Messiah", "version": 1}`)
+	send(http.MethodPut, "/books/9", host, `{"name": "Missing", "version": 1}`)
+	send(http.MethodDelete, "/books/1", host, "")
+	send(http.MethodDelete, "/books", host, `{"ids": [1, 2, 3, 9]}`) // 1 is already deleted, 9 never existed
+	send(http.MethodDelete, "/tenants/1?confirm=true", "", "")
+
+	type entry struct{ requestID, tenant, action, entity, id string }
+	var got []entry
+	for _, ev := range a.events {
+		assert.False(t, ev.Time.IsZero())
+		got = append(got, entry{ev.RequestID, ev.Tenant, ev.Action, ev.Entity, ev.EntityID})
+	}
+	assert.Equal(t, []entry{
+		{"req-POST", "tenant1", AuditCreate, AuditTenant, "1"},
+		{"req-POST", "tenant1", AuditCreate, AuditBook, "1"},
+		{"req-POST", "tenant1", AuditCreate, AuditBook, "2"},
+		{"req-POST", "tenant1", AuditCreate, AuditBook, "3"},
+		{"req-PUT", "tenant1", AuditUpdate, AuditBook, "1"},
+		{"req-DELETE", "tenant1", AuditDelete, AuditBook, "1"},
+		{"req-DELETE", "tenant1", AuditDelete, AuditBook, "2,3"},
+		{"req-DELETE", "tenant1", AuditDelete, AuditTenant, "1"},
+	}, got, "only committed changes are audited")
+}
+
+func TestAuditFailure(t *testing.T) {
+	var logs bytes.Buffer
+	a := &recordingAuditor{err: errors.New("sink unavailable")}
+	_, e := newSQLiteServer(t, WithAuditLogger(a), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "mutation succeeds: %s", rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", "tenant1.example.com", `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "mutation succeeds: %s", rr.Body.String())
+	rr = serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+	assert.Contains(t, rr.Body.String(), "Dune", "mutation is not rolled back")
+	assert.Contains(t, logs.String(), "Failed to write audit event")
+	assert.Contains(t, logs.String(), "sink unavailable")
+}
+
+func TestSlogAuditLogger(t *testing.T) {
+	var logs bytes.Buffer
+	_, e := newSQLiteServer(t, WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Contains(t, logs.String(), `"msg":"Audit"`)
+	assert.Contains(t, logs.String(), `"action":"create","entity":"tenant","entity_id":"1"`)
+}
@@ -0,0 +1,59 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoMigrate(t *testing.T) {
+	const (
+		token = "admin-secret"
+		host  = "tenant1.example.com"
+	)
+
+	t.Run("Auto", func(t *testing.T) {
+		_, e := newSQLiteServer(t, WithAdminToken(token))
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.Equal(t, models.TenantActive, decode[models.TenantResponse](t, rr).Status)
+
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		assert.Equal(t, http.StatusOK, rr.Code, "the tenant is served right away: %s", rr.Body.String())
+	})
+
+	t.Run("Manual", func(t *testing.T) {
+		cr, e := newSQLiteServer(t, WithAdminToken(token), WithAutoMigrate(false))
+		rr := serve(e, http.MethodPost, "/tenants?seed=true", "", `{"domainUrl": "`+host+`"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "a pending tenant cannot be seeded: %s", rr.Body.String())
+
+		rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.Equal(t, models.TenantPending, decode[models.TenantResponse](t, rr).Status)
+		_, err := cr.db.UseTenant(context.Background(), "tenant1")
+		assert.Error(t, err, "no schema is created")
+
+		rr = serve(e, http.MethodGet, "/tenants/1", "", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, models.TenantPending, decode[models.TenantResponse](t, rr).Status)
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		assert.Equal(t, http.StatusLocked, rr.Code, "a pending tenant is not served")
+
+		rr = serveAdmin(e, http.MethodPost, "/tenants/migrate", token)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Empty(t, decode[models.MigrateTenantsResponse](t, rr).Migrated, "pending tenants are left to be migrated one by one")
+		rr = serveAdmin(e, http.MethodPost, "/admin/reconcile", token)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Empty(t, decode[models.ReconcileResponse](t, rr).MissingSchemas, "a pending tenant has no schema yet")
+
+		rr = serveAdmin(e, http.MethodPost, "/tenants/1/migrate", token)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, models.TenantActive, decode[models.TenantResponse](t, rr).Status)
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		assert.Equal(t, http.StatusOK, rr.Code, "the migrated tenant is served: %s", rr.Body.String())
+	})
+}
@@ -0,0 +1,63 @@
+package echoserver
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	echomw "github.com/bartventer/gorm-multitenancy/middleware/echo/v8"
+	"github.com/labstack/echo/v4"
+)
+
+// Reasons a subdomain is unavailable.
+const (
+	ReasonInvalidDomain = "invalid domain"
+	ReasonInvalidSchema = "invalid schema name"
+	ReasonReserved      = "reserved"
+	ReasonTaken         = "taken"
+)
+
+// schemaNamePattern matches the schema names tenants may use: an identifier
+// that needs no quoting, of 3 to 63 characters.
+var schemaNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{2,62}$`)
+
+// validSchemaName reports whether name may be used as a tenant schema.
+func validSchemaName(name string) bool {
+	return schemaNamePattern.MatchString(name)
+}
+
+// availabilityHandler reports whether a tenant could be created for the
+// domain or subdomain query parameter. Only the subdomain is echoed back, so
+// the domains of other tenants are never revealed.
+func (cr *controller) availabilityHandler(c echo.Context) error {
+	subdomain := c.QueryParam("subdomain")
+	if domain := c.QueryParam("domain"); domain != "" {
+		var err error
+		if subdomain, err = echomw.ExtractSubdomain(domain); err != nil {
+			return respond(c, http.StatusOK, models.AvailabilityResponse{Reason: ReasonInvalidDomain})
+		}
+	} else if subdomain == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "domain or subdomain is required")
+	}
+
+	res := models.AvailabilityResponse{Subdomain: subdomain}
+	switch {
+	case !validSchemaName(subdomain):
+		res.Reason = ReasonInvalidSchema
+	case cr.isReservedSchema(subdomain):
+		res.Reason = ReasonReserved
+	default:
+		// Deleted tenants keep their schema name.
+		var n int64
+		if err := cr.db.WithContext(c.Request().Context()).Unscoped().Model(&models.Tenant{}).
+			Where("schema_name = ?", subdomain).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			res.Reason = ReasonTaken
+		} else {
+			res.Available = true
+		}
+	}
+	return respond(c, http.StatusOK, res)
+}
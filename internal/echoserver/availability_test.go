@@ -0,0 +1,47 @@
+package echoserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvailability(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	rr := serve(e, http.MethodDelete, "/tenants/2?confirm=true", "", "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	tests := []struct {
+		query string
+		want  models.AvailabilityResponse
+	}{
+		{"domain=tenant1.example.com", models.AvailabilityResponse{Subdomain: "tenant1", Reason: ReasonTaken}},
+		{"subdomain=tenant1", models.AvailabilityResponse{Subdomain: "tenant1", Reason: ReasonTaken}},
+		{"subdomain=tenant2", models.AvailabilityResponse{Subdomain: "tenant2", Reason: ReasonTaken}},
+		{"domain=tenant3.example.com", models.AvailabilityResponse{Subdomain: "tenant3", Available: true}},
+		{"subdomain=tenant3", models.AvailabilityResponse{Subdomain: "tenant3", Available: true}},
+		{"domain=example.com", models.AvailabilityResponse{Reason: ReasonInvalidDomain}},
+		{"domain=ten-ant.example.com", models.AvailabilityResponse{Subdomain: "ten-ant", Reason: ReasonInvalidSchema}},
+		{"subdomain=t1", models.AvailabilityResponse{Subdomain: "t1", Reason: ReasonInvalidSchema}},
+		{"subdomain=public", models.AvailabilityResponse{Subdomain: "public", Reason: ReasonReserved}},
+	}
+	for _, tt := range tests {
+		rr := serve(e, http.MethodGet, "/tenants/available?"+tt.query, "", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, tt.want, decode[models.AvailabilityResponse](t, rr), tt.query)
+		assert.NotContains(t, rr.Body.String(), "example.com", "domains are not revealed")
+	}
+
+	rr = serve(e, http.MethodGet, "/tenants/available", "", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "ten-ant.example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "invalid schema names cannot be created either")
+}
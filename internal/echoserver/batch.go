@@ -0,0 +1,80 @@
+package echoserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
+)
+
+// maxBatchDeleteIDs caps the number of books deleted by one request.
+const maxBatchDeleteIDs = 100
+
+// batchDeleteBooksHandler deletes the tenant's books with the given IDs in a
+// single transaction. IDs that do not exist in the tenant's schema, including
+// IDs of other tenants' books, are ignored and neither counted nor audited.
+func (cr *controller) batchDeleteBooksHandler(c echo.Context) error {
+	tenantID, err := mustTenant(c)
+	if err != nil {
+		return err
+	}
+	var body models.BatchDeleteBooksBody
+	if err = c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	switch n := len(body.IDs); {
+	case n == 0:
+		return echo.NewHTTPError(http.StatusBadRequest, "ids is required")
+	case n > maxBatchDeleteIDs:
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("at most %d ids may be deleted at once", maxBatchDeleteIDs))
+	}
+
+	db, err := cr.tenantDB(c.Request().Context(), tenantID)
+	if err != nil {
+		return err
+	}
+	var (
+		res     models.BatchDeleteBooksResponse
+		deleted []uint
+	)
+	if err = db.Transaction(func(tx *multitenancy.DB) error {
+		// Select the IDs that exist first, so that only those are audited.
+		// The rows are locked until the transaction ends, so a concurrent
+		// delete cannot remove one between the select and the delete: every
+		// ID selected is one this request deletes.
+		if err := tx.Scopes(scopes.WithTenantSchema(tenantID)).Model(&models.Book{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", body.IDs).Order("id").Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		result := tx.Scopes(scopes.WithTenantSchema(tenantID)).Delete(&models.Book{}, deleted)
+		if result.Error != nil {
+			return result.Error
+		}
+		res.Deleted = result.RowsAffected
+		if res.Deleted == 0 {
+			return nil
+		}
+		return adjustBookCount(tx.DB, tenantID, -res.Deleted)
+	}); err != nil {
+		return err
+	}
+	if res.Deleted > 0 {
+		ids := make([]string, len(deleted))
+		for i, id := range deleted {
+			ids[i] = strconv.FormatUint(uint64(id), 10)
+		}
+		cr.audit(c.Request().Context(), tenantID, AuditDelete, AuditBook, strings.Join(ids, ","))
+	}
+	setAffectedRows(c, res.Deleted)
+	return respond(c, http.StatusOK, res)
+}
@@ -0,0 +1,66 @@
+package echoserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchDeleteBooks(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host1, host2 = "tenant1.example.com", "tenant2.example.com"
+	for _, host := range []string{host1, host2} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	for i := range 3 {
+		rr := serve(e, http.MethodPost, "/books", host1, fmt.Sprintf(`{"name": "Book %d", "author": "Author"}`, i+1))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	for i := range 5 {
+		rr := serve(e, http.MethodPost, "/books", host2, fmt.Sprintf(`{"name": "Book %d", "author": "Author"}`, i+1))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	// IDs 4 and 5 only exist in tenant2.
+	rr := serve(e, http.MethodDelete, "/books", host1, `{"ids": [1, 3, 4, 5]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"deleted": 2}`, rr.Body.String())
+
+	rr = serve(e, http.MethodGet, "/books/count", host1, "")
+	assert.JSONEq(t, `{"count": 1}`, rr.Body.String())
+	rr = serve(e, http.MethodGet, "/books/2", host1, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	for _, id := range []int{1, 3} {
+		rr = serve(e, http.MethodGet, fmt.Sprintf("/books/%d", id), host1, "")
+		assert.Equal(t, http.StatusNotFound, rr.Code, id)
+	}
+	rr = serve(e, http.MethodGet, "/books/count", host2, "")
+	assert.JSONEq(t, `{"count": 5}`, rr.Body.String(), "no cross-tenant deletion")
+	for _, id := range []int{1, 3, 4, 5} {
+		rr = serve(e, http.MethodGet, fmt.Sprintf("/books/%d", id), host2, "")
+		assert.Equal(t, http.StatusOK, rr.Code, id)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		ids := make([]string, maxBatchDeleteIDs+1)
+		for i := range ids {
+			ids[i] = fmt.Sprint(i + 1)
+		}
+		for name, body := range map[string]string{
+			"Empty":   `{"ids": []}`,
+			"Missing": `{}`,
+			"TooMany": `{"ids": [` + strings.Join(ids, ",") + `]}`,
+		} {
+			rr := serve(e, http.MethodDelete, "/books", host2, body)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, name)
+		}
+		rr := serve(e, http.MethodGet, "/books/count", host2, "")
+		assert.Equal(t, models.BookCountResponse{Count: 5}, decode[models.BookCountResponse](t, rr))
+	})
+}
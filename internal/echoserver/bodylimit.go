@@ -0,0 +1,135 @@
+package echoserver
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	errBodyTooLarge = errors.New("request body too large")
+	errBodyBudget   = errors.New("request body budget exhausted")
+)
+
+// bodyBudget bounds the bytes of the request bodies being read at once.
+type bodyBudget struct {
+	mu        sync.Mutex
+	used, max int64
+}
+
+// reserve takes n bytes from the budget, reporting false if they do not fit.
+func (b *bodyBudget) reserve(n int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.max {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+func (b *bodyBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+}
+
+// limitedBody is a request body bounded to limit bytes, whose bytes are
+// taken from budget as they are read.
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64       // limit is the maximum size of the body; zero means no limit.
+	budget   *bodyBudget // budget is nil if only the size of the body is limited.
+	read     int64
+	reserved int64
+	err      error // err is errBodyTooLarge or errBodyBudget once reading failed for either.
+}
+
+// reserve grows the body's share of the budget to n bytes.
+func (b *limitedBody) reserve(n int64) bool {
+	if b.budget == nil || n <= b.reserved {
+		return true
+	}
+	if !b.budget.reserve(n - b.reserved) {
+		return false
+	}
+	b.reserved = n
+	return true
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	switch {
+	case b.limit > 0 && b.read > b.limit:
+		b.err = errBodyTooLarge
+	case !b.reserve(b.read):
+		b.err = errBodyBudget
+	default:
+		return n, err
+	}
+	return 0, b.err
+}
+
+// release returns the body's share of the budget.
+func (b *limitedBody) release() {
+	if b.budget != nil {
+		b.budget.release(b.reserved)
+		b.reserved = 0
+	}
+}
+
+// limitBodies returns a middleware bounding each request body to
+// [Options.MaxBodyBytes], failing larger ones with 413, and the bodies in
+// flight to [Options.BodyBudget] bytes in total, shedding requests beyond it
+// with 503 so that many medium-sized bodies cannot exhaust memory together.
+// A body's bytes count against the budget from when they are read, or from
+// the start for its declared Content-Length, until its request is served.
+func (cr *controller) limitBodies() echo.MiddlewareFunc {
+	var budget *bodyBudget
+	if cr.opts.BodyBudget > 0 {
+		budget = &bodyBudget{max: cr.opts.BodyBudget}
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Body == nil || req.Body == http.NoBody {
+				return next(c)
+			}
+			body := &limitedBody{ReadCloser: req.Body, limit: cr.opts.MaxBodyBytes, budget: budget}
+			defer body.release()
+			// Refuse what is known not to fit before reading any of it.
+			if body.limit > 0 && req.ContentLength > body.limit {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, errBodyTooLarge.Error())
+			}
+			if req.ContentLength > 0 && !body.reserve(req.ContentLength) {
+				return bodyBudgetError(c)
+			}
+			req.Body = body
+
+			err := next(c)
+			if c.Response().Committed {
+				return err
+			}
+			// Handlers report unreadable bodies as malformed; report why.
+			switch body.err {
+			case errBodyTooLarge:
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, errBodyTooLarge.Error())
+			case errBodyBudget:
+				return bodyBudgetError(c)
+			}
+			return err
+		}
+	}
+}
+
+func bodyBudgetError(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, inFlightRetryAfter)
+	return echo.NewHTTPError(http.StatusServiceUnavailable, "server is busy, please retry")
+}
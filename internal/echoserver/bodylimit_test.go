@@ -0,0 +1,81 @@
+package echoserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upload posts size bytes to path, unsized if chunked.
+func upload(e *echo.Echo, path string, size int, chunked bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(strings.Repeat("x", size)))
+	if chunked {
+		req.ContentLength = -1
+	}
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBodyLimits(t *testing.T) {
+	const perRequest, total = 800, 1000
+	_, e := newServer(nil, WithBodyLimits(perRequest, total))
+	read, release := make(chan struct{}, 1), make(chan struct{})
+	e.POST("/admin/hold", func(c echo.Context) error {
+		if _, err := io.ReadAll(c.Request().Body); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		read <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusNoContent)
+	})
+	e.POST("/admin/upload", func(c echo.Context) error {
+		if _, err := io.ReadAll(c.Request().Body); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	for _, chunked := range []bool{false, true} {
+		rr := upload(e, "/admin/upload", 600, chunked)
+		require.Equal(t, http.StatusNoContent, rr.Code, "chunked %t: %s", chunked, rr.Body.String())
+	}
+
+	t.Run("OverBudget", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusNoContent, upload(e, "/admin/hold", 600, false).Code)
+		}()
+		<-read
+
+		// Each upload is under the per-request limit, but not with the one held.
+		for _, chunked := range []bool{false, true} {
+			rr := upload(e, "/admin/upload", 600, chunked)
+			assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "chunked %t: %s", chunked, rr.Body.String())
+			assert.Equal(t, "1", rr.Header().Get(echo.HeaderRetryAfter), "chunked %t", chunked)
+		}
+		rr := upload(e, "/admin/upload", 300, false)
+		assert.Equal(t, http.StatusNoContent, rr.Code, "what is left of the budget is served: %s", rr.Body.String())
+
+		close(release)
+		wg.Wait()
+		rr = upload(e, "/admin/upload", 600, false)
+		assert.Equal(t, http.StatusNoContent, rr.Code, "the budget is released: %s", rr.Body.String())
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		for _, chunked := range []bool{false, true} {
+			rr := upload(e, "/admin/upload", perRequest+100, chunked)
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "chunked %t: %s", chunked, rr.Body.String())
+		}
+	})
+}
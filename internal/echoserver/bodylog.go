@@ -0,0 +1,155 @@
+package echoserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultBodyLogLimit is the size in bytes above which bodies are not
+// logged by default.
+const defaultBodyLogLimit = 4096
+
+// redactedValue replaces the values of redacted fields in logged bodies.
+const redactedValue = "***"
+
+// defaultRedactedFields are the JSON fields always redacted from logged
+// bodies, in addition to [Options.RedactedFields].
+var defaultRedactedFields = []string{"password", "token", "secret", "apiKey"}
+
+// logBodies returns a middleware logging the JSON request and response
+// bodies of each request, with the fields named by [Options.RedactedFields]
+// redacted at any depth. Bodies are captured as they are read and written,
+// up to [Options.BodyLogLimit]; a longer body cannot be reliably redacted
+// once cut, so only its size is logged. It must not be applied to streamed
+// routes, whose responses would be held in memory.
+func (cr *controller) logBodies() echo.MiddlewareFunc {
+	limit := cr.opts.BodyLogLimit
+	if limit <= 0 {
+		limit = defaultBodyLogLimit
+	}
+	redacted := make(map[string]bool)
+	for _, f := range slices.Concat(defaultRedactedFields, cr.opts.RedactedFields) {
+		redacted[strings.ToLower(f)] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Header.Get(echo.HeaderUpgrade) != "" {
+				return next(c) // the connection is about to be hijacked
+			}
+			reqBody := &cappedBuffer{limit: limit}
+			req.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(req.Body, reqBody), req.Body}
+			rec := &bodyRecorder{ResponseWriter: c.Response().Writer, body: cappedBuffer{limit: limit}}
+			c.Response().Writer = rec
+
+			err := next(c)
+			if err != nil {
+				// Let the error handler write the response, so that it is
+				// logged; it leaves committed responses alone afterwards.
+				c.Error(err)
+			}
+			c.Response().Writer = rec.ResponseWriter
+
+			res := c.Response()
+			cr.logger().InfoContext(req.Context(), "HTTP bodies",
+				"request_id", res.Header().Get(echo.HeaderXRequestID),
+				"method", req.Method,
+				"path", req.URL.Path,
+				"status", res.Status,
+				"request_body", loggedBody(reqBody, req.Header.Get(echo.HeaderContentType), redacted),
+				"response_body", loggedBody(&rec.body, res.Header().Get(echo.HeaderContentType), redacted),
+			)
+			return err
+		}
+	}
+}
+
+// loggedBody returns the body captured in b, of the given content type, as
+// it is logged: JSON with its redacted fields replaced, or a summary.
+func loggedBody(b *cappedBuffer, contentType string, redacted map[string]bool) any {
+	if b.n == 0 {
+		return nil
+	}
+	if b.n > int64(b.limit) {
+		return fmt.Sprintf("<%d bytes, over the logging limit>", b.n)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != echo.MIMEApplicationJSON {
+		return fmt.Sprintf("<%d bytes of %s>", b.n, contentType)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Sprintf("<%d bytes of malformed JSON>", b.n)
+	}
+	return redact(v, redacted)
+}
+
+// redact replaces the values of the redacted fields in v, a decoded JSON
+// value. Field names are matched case-insensitively.
+func redact(v any, redacted map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, fv := range v {
+			if redacted[strings.ToLower(k)] {
+				v[k] = redactedValue
+			} else {
+				v[k] = redact(fv, redacted)
+			}
+		}
+	case []any:
+		for i, ev := range v {
+			v[i] = redact(ev, redacted)
+		}
+	}
+	return v
+}
+
+// cappedBuffer keeps the first limit+1 bytes written to it, while counting
+// them all, so that a body over the limit is told apart from one at it.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	n     int64
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.n += int64(len(p))
+	if room := b.limit + 1 - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// bodyRecorder copies the start of the body written to a response.
+type bodyRecorder struct {
+	http.ResponseWriter
+	body cappedBuffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	_, _ = w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *bodyRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
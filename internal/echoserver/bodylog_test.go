@@ -0,0 +1,114 @@
+package echoserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bodyLogs returns the body log entries written to logs.
+func bodyLogs(t *testing.T, logs *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	s := bufio.NewScanner(logs)
+	for s.Scan() {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(s.Bytes(), &entry), s.Text())
+		if entry["msg"] == "HTTP bodies" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestBodyLogging(t *testing.T) {
+	var logs bytes.Buffer
+	logger := WithLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	t.Run("Redacted", func(t *testing.T) {
+		logs.Reset()
+		cr, e := newServer(nil, logger, WithBodyLogging(true, "pin"))
+		e.POST("/admin/echo", func(c echo.Context) error {
+			var body map[string]any
+			if err := c.Bind(&body); err != nil {
+				return err
+			}
+			body["secret"] = "s3cr3t"
+			return c.JSON(http.StatusOK, body)
+		}, cr.logBodies())
+
+		rr := serve(e, http.MethodPost, "/admin/echo", "",
+			`{"name": "Dune", "token": "abc123", "account": {"PIN": "0000", "tags": [{"password": "hunter2"}]}}`)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		entries := bodyLogs(t, &logs)
+		require.Len(t, entries, 1)
+		assert.Equal(t, map[string]any{
+			"name":    "Dune",
+			"token":   "***",
+			"account": map[string]any{"PIN": "***", "tags": []any{map[string]any{"password": "***"}}},
+		}, entries[0]["request_body"])
+		res, ok := entries[0]["response_body"].(map[string]any)
+		require.True(t, ok, entries[0]["response_body"])
+		assert.Equal(t, "***", res["secret"])
+		assert.Equal(t, "Dune", res["name"])
+		for _, secret := range []string{"abc123", "0000", "hunter2", "s3cr3t"} {
+			assert.NotContains(t, logs.String(), secret)
+		}
+		assert.Contains(t, rr.Body.String(), "s3cr3t", "the response itself is not redacted")
+	})
+
+	t.Run("Routes", func(t *testing.T) {
+		logs.Reset()
+		_, e := newSQLiteServer(t, logger, WithBodyLogging(true, "author"))
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodPost, "/books", "tenant1.example.com", `{"name": "Dune"}`)
+		require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodGet, "/tenants/jobs/unknown/logs", "", "")
+		require.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+
+		entries := bodyLogs(t, &logs)
+		require.Len(t, entries, 2, "streamed routes are not logged")
+		assert.Equal(t, "/tenants", entries[0]["path"])
+		assert.Equal(t, map[string]any{"domainUrl": "tenant1.example.com"}, entries[0]["request_body"])
+		assert.EqualValues(t, http.StatusBadRequest, entries[1]["status"])
+		res, ok := entries[1]["response_body"].(map[string]any)
+		require.True(t, ok, entries[1]["response_body"])
+		assert.Equal(t, "request validation failed", res["message"], "error responses are logged")
+	})
+
+	t.Run("OverLimit", func(t *testing.T) {
+		logs.Reset()
+		cr, e := newServer(nil, logger, WithBodyLogging(true), WithBodyLogLimit(16))
+		e.POST("/admin/echo", func(c echo.Context) error {
+			var body map[string]any
+			if err := c.Bind(&body); err != nil {
+				return err
+			}
+			return c.NoContent(http.StatusNoContent)
+		}, cr.logBodies())
+
+		rr := serve(e, http.MethodPost, "/admin/echo", "", `{"name": "Dune", "token": "abc123"}`)
+		require.Equal(t, http.StatusNoContent, rr.Code)
+		entries := bodyLogs(t, &logs)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "<35 bytes, over the logging limit>", entries[0]["request_body"])
+		assert.Nil(t, entries[0]["response_body"])
+		assert.NotContains(t, logs.String(), "abc123")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		logs.Reset()
+		_, e := newServer(nil, logger)
+		serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+		assert.Empty(t, bodyLogs(t, &logs))
+	})
+}
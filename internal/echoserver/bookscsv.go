@@ -0,0 +1,110 @@
+package echoserver
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// MIMETextCSV is the media type of book exports.
+const MIMETextCSV = "text/csv; charset=utf-8"
+
+// csvFlushRows is the number of rows written between flushes of an export.
+const csvFlushRows = 100
+
+// bookCSVColumns are the columns of a book export, named like the fields of
+// [models.BookResponse].
+var bookCSVColumns = []string{"id", "name", "author", "isbn"}
+
+// filterBooks returns a scope keeping the books named by the name query
+// parameter of c, if any.
+func filterBooks(c echo.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if name := c.QueryParam("name"); name != "" {
+			return db.Where("name = ?", name)
+		}
+		return db
+	}
+}
+
+// exportBooksHandler streams the tenant's books as CSV, with a header row,
+// reading them from the database as they are written. Only the columns the
+// tenant's tier is entitled to are exported. The query is bound to the
+// request, so it stops when the client goes away.
+func (cr *controller) exportBooksHandler(c echo.Context) error {
+	tenantID, err := mustTenant(c)
+	if err != nil {
+		return err
+	}
+	ctx, end, err := cr.streams.begin(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	defer end()
+	columns := bookCSVColumns
+	fields, err := cr.tierFields(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if fields != nil {
+		columns = slices.DeleteFunc(slices.Clone(columns), func(col string) bool {
+			return !slices.Contains(fields, col)
+		})
+	}
+	db, err := cr.tenantDB(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	rows, err := db.Table(models.TableNameBook).Scopes(scopes.WithTenantSchema(tenantID), filterBooks(c)).
+		Select(columns).Where("deleted_at IS NULL").Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// The export may outlive the server's write timeout.
+	_ = http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{})
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, MIMETextCSV)
+	w.Header().Set(echo.HeaderContentDisposition, `attachment; filename="books.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write(columns)
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for n := 1; rows.Next(); n++ {
+		if err = rows.Scan(dest...); err != nil {
+			log.Printf("Failed to read exported book of tenant %q: %v", tenantID, err)
+			break
+		}
+		for i, v := range values {
+			record[i] = v.String
+		}
+		_ = cw.Write(record)
+		if n%csvFlushRows == 0 {
+			if cw.Flush(); cw.Error() != nil {
+				return nil // the client is gone
+			}
+			w.Flush()
+		}
+	}
+	if err = rows.Err(); err != nil && ctx.Err() == nil {
+		log.Printf("Failed to export books of tenant %q: %v", tenantID, err)
+	}
+	cw.Flush()
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,82 @@
+package echoserver
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportBooks(t *testing.T) {
+	_, e := newSQLiteServer(t, WithTierFields("free", "id", "name"))
+	const (
+		host = "tenant1.example.com"
+		free = "free.example.com"
+	)
+	for _, body := range []string{`{"domainUrl": "` + host + `"}`, `{"domainUrl": "` + free + `", "tier": "free"}`} {
+		rr := serve(e, http.MethodPost, "/tenants", "", body)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	for _, h := range []string{host, free} {
+		for _, book := range []string{
+			`{"name": "Dune", "author": "Frank Herbert", "isbn": "9780306406157"}`,
+			`{"name": "Emma, Volume 1", "author": "Jane \"Austen\""}`,
+		} {
+			rr := serve(e, http.MethodPost, "/books", h, book)
+			require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+	rr := serve(e, http.MethodDelete, "/books/1", free, "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	records := func(t *testing.T, rr *httptest.ResponseRecorder) [][]string {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, MIMETextCSV, rr.Header().Get(echo.HeaderContentType))
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.NoError(t, err)
+		return records
+	}
+
+	t.Run("All", func(t *testing.T) {
+		assert.Equal(t, [][]string{
+			{"id", "name", "author", "isbn"},
+			{"1", "Dune", "Frank Herbert", "9780306406157"},
+			{"2", "Emma, Volume 1", `Jane "Austen"`, ""},
+		}, records(t, serve(e, http.MethodGet, "/books/export", host, "")))
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		assert.Equal(t, [][]string{
+			{"id", "name", "author", "isbn"},
+			{"2", "Emma, Volume 1", `Jane "Austen"`, ""},
+		}, records(t, serve(e, http.MethodGet, "/books/export?name=Emma,+Volume+1", host, "")))
+		rr := serve(e, http.MethodGet, "/books?name=Dune", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.JSONEq(t, `[{"id": 1, "name": "Dune", "author": "Frank Herbert", "isbn": "9780306406157", "version": 1}]`, rr.Body.String(),
+			"the list takes the same filter")
+	})
+
+	t.Run("Tier", func(t *testing.T) {
+		assert.Equal(t, [][]string{
+			{"id", "name"},
+			{"2", "Emma, Volume 1"},
+		}, records(t, serve(e, http.MethodGet, "/books/export", free, "")), "deleted books and restricted fields are left out")
+	})
+
+	t.Run("Large", func(t *testing.T) {
+		const n = 2*csvFlushRows + 5
+		for i := range n {
+			rr := serve(e, http.MethodPost, "/books", host, `{"name": "Book `+strconv.Itoa(i)+`", "author": "Anonymous"}`)
+			require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		}
+		got := records(t, serve(e, http.MethodGet, "/books/export?name=Book+"+strconv.Itoa(n-1), host, ""))
+		assert.Len(t, got, 2)
+		assert.Len(t, records(t, serve(e, http.MethodGet, "/books/export", host, "")), 1+2+n)
+	})
+}
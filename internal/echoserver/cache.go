@@ -0,0 +1,45 @@
+package echoserver
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CacheStats reports the activity of a server-side cache.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Size   int    `json:"size"`
+}
+
+// Cache is a server-side cache that can be inspected and flushed through the
+// admin routes.
+type Cache interface {
+	Stats() CacheStats
+	Clear()
+}
+
+// registerCache makes c visible to the admin cache routes under name.
+// Caches must be registered before the server starts handling requests.
+func (cr *controller) registerCache(name string, c Cache) {
+	if cr.caches == nil {
+		cr.caches = make(map[string]Cache)
+	}
+	cr.caches[name] = c
+}
+
+func (cr *controller) cacheStatsHandler(c echo.Context) error {
+	stats := make(map[string]CacheStats, len(cr.caches))
+	for name, cache := range cr.caches {
+		stats[name] = cache.Stats()
+	}
+	return respond(c, http.StatusOK, stats)
+}
+
+func (cr *controller) clearCachesHandler(c echo.Context) error {
+	for _, cache := range cr.caches {
+		cache.Clear()
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package echoserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapCache is a minimal [Cache] used to exercise the admin cache routes.
+type mapCache struct {
+	entries      map[string]struct{}
+	hits, misses uint64
+}
+
+func (m *mapCache) get(key string) bool {
+	if _, ok := m.entries[key]; ok {
+		m.hits++
+		return true
+	}
+	m.misses++
+	m.entries[key] = struct{}{}
+	return false
+}
+
+func (m *mapCache) Stats() CacheStats {
+	return CacheStats{Hits: m.hits, Misses: m.misses, Size: len(m.entries)}
+}
+
+func (m *mapCache) Clear() {
+	m.entries = make(map[string]struct{})
+}
+
+func TestAdminCacheRoutes(t *testing.T) {
+	cache := &mapCache{entries: make(map[string]struct{})}
+	cr := &controller{opts: Options{AdminToken: "secret"}}
+	cr.registerCache("test", cache)
+	e := echo.New()
+	cr.init(e)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+	stats := func() map[string]CacheStats {
+		rr := do(http.MethodGet, "/admin/cache/stats", "secret")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var got map[string]CacheStats
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		return got
+	}
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/admin/cache/stats", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/admin/cache/clear", "wrong").Code)
+	})
+
+	t.Run("StatsReflectActivity", func(t *testing.T) {
+		cache.get("tenant1")
+		cache.get("tenant1")
+		cache.get("tenant2")
+		assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Size: 2}, stats()["test"])
+	})
+
+	t.Run("ClearEmptiesCaches", func(t *testing.T) {
+		rr := do(http.MethodPost, "/admin/cache/clear", "secret")
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Zero(t, stats()["test"].Size)
+	})
+}
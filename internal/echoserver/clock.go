@@ -0,0 +1,42 @@
+package echoserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	echomw "github.com/bartventer/gorm-multitenancy/middleware/echo/v8"
+	"github.com/labstack/echo/v4"
+)
+
+// location returns the server's configured time zone, defaulting to UTC.
+func (cr *controller) location() *time.Location {
+	if cr.opts.Location != nil {
+		return cr.opts.Location
+	}
+	return time.UTC
+}
+
+// timeHandler reports the server's current time and time zone, so clients
+// can detect clock skew. When the request host names a tenant with a time
+// zone of its own, that is reported too. The route is served without
+// resolving a tenant, so it never fails for want of one.
+func (cr *controller) timeHandler(c echo.Context) error {
+	res := models.TimeResponse{
+		Time:     time.Now().UTC(),
+		Timezone: cr.location().String(),
+	}
+	if tenantID, err := echomw.ExtractSubdomain(c.Request().Host); err == nil {
+		var zones []string
+		if err := cr.db.WithContext(c.Request().Context()).Model(&models.Tenant{}).
+			Where("schema_name = ?", tenantID).Limit(1).
+			Pluck("timezone", &zones).Error; err != nil {
+			return err
+		}
+		if len(zones) > 0 && zones[0] != "" {
+			res.Tenant = tenantID
+			res.TenantTimezone = zones[0]
+		}
+	}
+	return respond(c, http.StatusOK, res)
+}
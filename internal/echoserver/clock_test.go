@@ -0,0 +1,48 @@
+package echoserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTime(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	_, e := newSQLiteServer(t, WithLocation(paris))
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com", "timezone": "Asia/Tokyo"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant2.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	get := func(host string) map[string]string {
+		t.Helper()
+		rr := serve(e, http.MethodGet, "/time", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var res map[string]string
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		return res
+	}
+
+	before := time.Now()
+	res := get("example.com")
+	ts, err := time.Parse(time.RFC3339, res["time"])
+	require.NoError(t, err, "time is RFC 3339")
+	assert.Equal(t, time.UTC, ts.Location(), "time is UTC")
+	assert.WithinDuration(t, before, ts, time.Minute)
+	assert.Equal(t, map[string]string{"time": res["time"], "timezone": "Europe/Paris"}, res)
+
+	res = get("tenant1.example.com")
+	assert.Equal(t, "tenant1", res["tenant"])
+	assert.Equal(t, "Asia/Tokyo", res["tenantTimezone"])
+
+	res = get("tenant2.example.com")
+	assert.NotContains(t, res, "tenantTimezone", "tenant without a time zone")
+
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant3.example.com", "timezone": "Mars/Olympus"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "unknown time zones are rejected")
+}
@@ -0,0 +1,21 @@
+package echoserver
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const defaultCompressionMinLength = 1024
+
+// compress returns the gzip middleware. It buffers the response until the
+// minimum length is reached, so it is only applied to routes that are not
+// streamed.
+func (cr *controller) compress() echo.MiddlewareFunc {
+	minLength := cr.opts.CompressionMinLength
+	if minLength <= 0 {
+		minLength = defaultCompressionMinLength
+	}
+	return middleware.GzipWithConfig(middleware.GzipConfig{
+		MinLength: minLength,
+	})
+}
@@ -0,0 +1,51 @@
+package echoserver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	_, e := newSQLiteServer(t, WithCompression(0))
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = get("/books/count")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get(echo.HeaderContentEncoding), "small responses are not compressed")
+	assert.JSONEq(t, `{"count": 0}`, rr.Body.String())
+
+	want := make([]models.BookResponse, 50)
+	for i := range want {
+		want[i] = models.BookResponse{ID: uint(i + 1), Name: fmt.Sprintf("Book %d", i+1), Author: "Author", Version: 1}
+		rr := serve(e, http.MethodPost, "/books", host, fmt.Sprintf(`{"name": "Book %d", "author": "Author"}`, i+1))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	rr = get("/books")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "gzip", rr.Header().Get(echo.HeaderContentEncoding))
+	zr, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	var got []models.BookResponse
+	require.NoError(t, json.NewDecoder(zr).Decode(&got))
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,150 @@
+package echoserver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables read by [LoadConfigFromEnv].
+const (
+	EnvAddr            = "ECHOSERVER_ADDR"
+	EnvReadTimeout     = "ECHOSERVER_READ_TIMEOUT"
+	EnvWriteTimeout    = "ECHOSERVER_WRITE_TIMEOUT"
+	EnvShutdownTimeout = "ECHOSERVER_SHUTDOWN_TIMEOUT"
+	EnvRateLimit       = "ECHOSERVER_RATE_LIMIT"
+	EnvRateBurst       = "ECHOSERVER_RATE_BURST"
+	EnvSelfTest        = "ECHOSERVER_SELF_TEST"
+)
+
+// Config is the server configuration that may be set from the environment.
+type Config struct {
+	Addr            string        // Addr is the TCP address to listen on, ":8080" by default.
+	ReadTimeout     time.Duration // ReadTimeout bounds reading a request, 5 seconds by default.
+	WriteTimeout    time.Duration // WriteTimeout bounds writing a response, 10 seconds by default.
+	ShutdownTimeout time.Duration // ShutdownTimeout bounds the graceful shutdown, 5 seconds by default.
+
+	// RateLimit is the number of requests per second each tenant may make
+	// once its burst is spent. Zero, the default, disables rate limiting.
+	RateLimit float64
+
+	// RateBurst is the number of requests a tenant may make at once.
+	// Defaults to RateLimit, rounded up.
+	RateBurst int
+
+	// SelfTest runs the startup self-test, see [WithSelfTest]. Off by
+	// default.
+	SelfTest bool
+}
+
+// LoadConfigFromEnv reads the server configuration from the ECHOSERVER_*
+// environment variables, leaving the defaults for those that are unset or
+// empty. Timeouts are durations such as "30s", and ECHOSERVER_RATE_LIMIT is
+// a number of requests per second. All the invalid variables are reported.
+func LoadConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Addr:            defaultAddr,
+		ReadTimeout:     defaultReadTimeout,
+		WriteTimeout:    defaultWriteTimeout,
+		ShutdownTimeout: defaultShutdownTimeout,
+	}
+	var errs []error
+	if v, ok := lookupEnv(EnvAddr); ok {
+		if _, _, err := net.SplitHostPort(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvAddr, err))
+		} else {
+			cfg.Addr = v
+		}
+	}
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{EnvReadTimeout, &cfg.ReadTimeout},
+		{EnvWriteTimeout, &cfg.WriteTimeout},
+		{EnvShutdownTimeout, &cfg.ShutdownTimeout},
+	} {
+		if err := envDuration(d.name, d.dst); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if v, ok := lookupEnv(EnvRateLimit); ok {
+		limit, err := strconv.ParseFloat(v, 64)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", EnvRateLimit, err))
+		case limit < 0 || math.IsInf(limit, 0) || math.IsNaN(limit):
+			errs = append(errs, fmt.Errorf("%s: %q is not a non-negative rate", EnvRateLimit, v))
+		default:
+			cfg.RateLimit = limit
+		}
+	}
+	if v, ok := lookupEnv(EnvRateBurst); ok {
+		burst, err := strconv.Atoi(v)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", EnvRateBurst, err))
+		case burst <= 0:
+			errs = append(errs, fmt.Errorf("%s: %q is not a positive number of requests", EnvRateBurst, v))
+		default:
+			cfg.RateBurst = burst
+		}
+	}
+	if v, ok := lookupEnv(EnvSelfTest); ok {
+		if selfTest, err := strconv.ParseBool(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvSelfTest, err))
+		} else {
+			cfg.SelfTest = selfTest
+		}
+	}
+	if cfg.RateBurst == 0 && cfg.RateLimit > 0 {
+		cfg.RateBurst = int(math.Ceil(cfg.RateLimit))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, fmt.Errorf("loading config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Options returns the options configuring a server as cfg does.
+func (cfg Config) Options() []Option {
+	opts := []Option{
+		WithAddr(cfg.Addr),
+		WithServerTimeouts(cfg.ReadTimeout, cfg.WriteTimeout),
+		WithShutdownTimeout(cfg.ShutdownTimeout),
+		WithSelfTest(cfg.SelfTest),
+	}
+	if cfg.RateLimit > 0 {
+		opts = append(opts, WithRateLimit(cfg.RateLimit, cfg.RateBurst))
+	}
+	return opts
+}
+
+// lookupEnv returns the value of the environment variable name, reporting
+// whether it is set and not empty.
+func lookupEnv(name string) (string, bool) {
+	v, ok := os.LookupEnv(name)
+	return v, ok && v != ""
+}
+
+// envDuration sets d from the environment variable name, if set, which must
+// be a positive duration.
+func envDuration(name string, d *time.Duration) error {
+	v, ok := lookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if parsed <= 0 {
+		return fmt.Errorf("%s: %q is not a positive duration", name, v)
+	}
+	*d = parsed
+	return nil
+}
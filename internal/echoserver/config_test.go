@@ -0,0 +1,113 @@
+package echoserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// setEnv sets the given environment variables, leaving the others of
+// [LoadConfigFromEnv] empty, for the duration of the test.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{EnvAddr, EnvReadTimeout, EnvWriteTimeout, EnvShutdownTimeout, EnvRateLimit, EnvRateBurst, EnvSelfTest} {
+		t.Setenv(name, env[name])
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		setEnv(t, nil)
+		cfg, err := LoadConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, Config{
+			Addr:            ":8080",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+		}, cfg)
+
+		var opts Options
+		for _, opt := range cfg.Options() {
+			opt(&opts)
+		}
+		assert.Zero(t, opts.RateLimit, "rate limiting is disabled")
+	})
+
+	t.Run("Parsed", func(t *testing.T) {
+		setEnv(t, map[string]string{
+			EnvAddr:            "127.0.0.1:9090",
+			EnvReadTimeout:     "2s",
+			EnvWriteTimeout:    "1m30s",
+			EnvShutdownTimeout: "250ms",
+			EnvRateLimit:       "2.5",
+			EnvSelfTest:        "true",
+		})
+		cfg, err := LoadConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, Config{
+			Addr:            "127.0.0.1:9090",
+			ReadTimeout:     2 * time.Second,
+			WriteTimeout:    90 * time.Second,
+			ShutdownTimeout: 250 * time.Millisecond,
+			RateLimit:       2.5,
+			RateBurst:       3,
+			SelfTest:        true,
+		}, cfg)
+
+		var opts Options
+		for _, opt := range cfg.Options() {
+			opt(&opts)
+		}
+		assert.Equal(t, "127.0.0.1:9090", opts.Addr)
+		assert.Equal(t, 2*time.Second, opts.ReadTimeout)
+		assert.Equal(t, 90*time.Second, opts.WriteTimeout)
+		assert.Equal(t, 250*time.Millisecond, opts.ShutdownTimeout)
+		assert.Equal(t, rate.Limit(2.5), opts.RateLimit)
+		assert.Equal(t, 3, opts.RateBurst)
+		assert.True(t, opts.SelfTest)
+	})
+
+	t.Run("Burst", func(t *testing.T) {
+		setEnv(t, map[string]string{EnvRateLimit: "10", EnvRateBurst: "50"})
+		cfg, err := LoadConfigFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, 50, cfg.RateBurst)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			name, value, message string
+		}{
+			{EnvAddr, "8080", "missing port in address"},
+			{EnvReadTimeout, "5", "missing unit in duration"},
+			{EnvWriteTimeout, "soon", "invalid duration"},
+			{EnvShutdownTimeout, "-1s", "not a positive duration"},
+			{EnvShutdownTimeout, "0s", "not a positive duration"},
+			{EnvRateLimit, "fast", "invalid syntax"},
+			{EnvRateLimit, "-1", "not a non-negative rate"},
+			{EnvRateBurst, "0", "not a positive number of requests"},
+			{EnvSelfTest, "maybe", "invalid syntax"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+				setEnv(t, map[string]string{tt.name: tt.value})
+				_, err := LoadConfigFromEnv()
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.name)
+				assert.ErrorContains(t, err, tt.message)
+			})
+		}
+	})
+
+	t.Run("AllInvalid", func(t *testing.T) {
+		setEnv(t, map[string]string{EnvReadTimeout: "5", EnvRateLimit: "fast"})
+		_, err := LoadConfigFromEnv()
+		require.Error(t, err)
+		assert.ErrorContains(t, err, EnvReadTimeout)
+		assert.ErrorContains(t, err, EnvRateLimit, "every invalid variable is reported")
+	})
+}
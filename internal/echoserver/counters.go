@@ -0,0 +1,113 @@
+package echoserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultCounterReconcileInterval = 10 * time.Minute
+
+// adjustBookCount atomically adds delta to the tenant's book counter. It must
+// run in the transaction making the change being counted, so the counter
+// commits or rolls back with it.
+func adjustBookCount(tx *gorm.DB, tenantID string, delta int64) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "tenant_schema"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"book_count": gorm.Expr("book_counters.book_count + ?", delta),
+		}),
+	}).Create(&models.BookCounter{TenantSchema: tenantID, BookCount: delta}).Error
+}
+
+// bookCountHandler reports the tenant's book count from its counter.
+func (cr *controller) bookCountHandler(c echo.Context) error {
+	tenantID, err := mustTenant(c)
+	if err != nil {
+		return err
+	}
+	db, err := cr.tenantDB(c.Request().Context(), tenantID)
+	if err != nil {
+		return err
+	}
+	var res models.BookCountResponse
+	if err = db.Model(&models.BookCounter{}).
+		Where("tenant_schema = ?", tenantID).
+		Select("book_count").Scan(&res.Count).Error; err != nil {
+		return err
+	}
+	return respond(c, http.StatusOK, res)
+}
+
+// reconcileBookCount resets the tenant's counter to the actual number of
+// books. The counter row is locked before counting: writers update it in
+// their own transactions, so they wait for the reconciliation, and books
+// they have not committed yet are added by them rather than counted here.
+func (cr *controller) reconcileBookCount(ctx context.Context, tenantID string) error {
+	db, err := cr.tenantDB(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *multitenancy.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.BookCounter{TenantSchema: tenantID}).Error; err != nil {
+			return err
+		}
+		var counter models.BookCounter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&counter, "tenant_schema = ?", tenantID).Error; err != nil {
+			return err
+		}
+		var n int64
+		if err := tx.Table(models.TableNameBook).Scopes(scopes.WithTenantSchema(tenantID)).
+			Where("deleted_at IS NULL").Count(&n).Error; err != nil {
+			return err
+		}
+		if n == counter.BookCount {
+			return nil
+		}
+		log.Printf("Book counter of tenant %q drifted: %d, actual %d", tenantID, counter.BookCount, n)
+		return tx.Model(&counter).Update("book_count", n).Error
+	})
+}
+
+// reconcileBookCounts reconciles the book counter of every tenant.
+func (cr *controller) reconcileBookCounts(ctx context.Context) {
+	var schemas []string
+	if err := cr.db.WithContext(ctx).Model(&models.Tenant{}).Scopes(onboardedTenants).Pluck("schema_name", &schemas).Error; err != nil {
+		log.Printf("Failed to list tenants to reconcile book counters: %v", err)
+		return
+	}
+	for _, schema := range schemas {
+		if err := cr.reconcileBookCount(ctx, schema); err != nil {
+			log.Printf("Failed to reconcile book counter of tenant %q: %v", schema, err)
+		}
+	}
+}
+
+// reconcileBookCountsLoop reconciles the book counters on start, then every
+// [Options.CounterReconcileInterval] until ctx is done.
+func (cr *controller) reconcileBookCountsLoop(ctx context.Context) {
+	interval := cr.opts.CounterReconcileInterval
+	if interval <= 0 {
+		interval = defaultCounterReconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cr.reconcileBookCounts(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package echoserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookCounters(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	hosts := map[string]string{"tenant1": "tenant1.example.com", "tenant2": "tenant2.example.com"}
+	for _, host := range hosts {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	count := func(host string) int64 {
+		rr := serve(e, http.MethodGet, "/books/count", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		return decode[models.BookCountResponse](t, rr).Count
+	}
+	actual := func(tenant string) int64 {
+		var n int64
+		require.NoError(t, cr.db.Table(models.TableNameBook).Scopes(scopes.WithTenantSchema(tenant)).
+			Where("deleted_at IS NULL").Count(&n).Error)
+		return n
+	}
+	assert.Zero(t, count(hosts["tenant1"]), "tenants without books count zero")
+
+	const writers, writes = 4, 10
+	var wg sync.WaitGroup
+	for tenant, host := range hosts {
+		for w := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range writes {
+					rr := serve(e, http.MethodPost, "/books", host, fmt.Sprintf(`{"name": "%s - Book %d.%d", "author": "Author"}`, tenant, w, i))
+					if !assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String()) {
+						return
+					}
+					if i%2 == 0 {
+						book := decode[models.BookResponse](t, rr)
+						// Delete twice concurrently: only one delete may count.
+						var dwg sync.WaitGroup
+						for range 2 {
+							dwg.Add(1)
+							go func() {
+								defer dwg.Done()
+								serve(e, http.MethodDelete, fmt.Sprintf("/books/%d", book.ID), host, "")
+							}()
+						}
+						dwg.Wait()
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for tenant, host := range hosts {
+		assert.EqualValues(t, writers*writes/2, actual(tenant), tenant)
+		assert.Equal(t, actual(tenant), count(host), "counter of %s matches COUNT", tenant)
+	}
+}
+
+func TestReconcileBookCounts(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	for i := range 3 {
+		rr = serve(e, http.MethodPost, "/books", host, fmt.Sprintf(`{"name": "Book %d", "author": "Author"}`, i))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	// Simulate drift, e.g. books written by another process.
+	require.NoError(t, cr.db.Model(&models.BookCounter{}).Where("tenant_schema = ?", "tenant1").
+		Update("book_count", 42).Error)
+	cr.reconcileBookCounts(context.Background())
+
+	rr = serve(e, http.MethodGet, "/books/count", host, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"count": 3}`, rr.Body.String())
+}
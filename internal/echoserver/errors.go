@@ -0,0 +1,197 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ErrorCategory is a portable classification of database errors, so clients
+// are not coupled to the specifics of the underlying driver.
+type ErrorCategory string
+
+const (
+	CategoryInternal         ErrorCategory = "internal"          // CategoryInternal is an unclassified error.
+	CategoryNotFound         ErrorCategory = "not_found"         // CategoryNotFound is a missing record.
+	CategoryTenantNotFound   ErrorCategory = "tenant_not_found"  // CategoryTenantNotFound is a missing tenant schema.
+	CategoryTenantOffboarded ErrorCategory = "tenant_offboarded" // CategoryTenantOffboarded is a missing tenant table, its schema dropped during the request.
+	CategoryConflict         ErrorCategory = "conflict"          // CategoryConflict is a unique constraint violation.
+	CategoryInvalidReference ErrorCategory = "invalid_reference" // CategoryInvalidReference is a foreign key violation.
+	CategoryMissingField     ErrorCategory = "missing_field"     // CategoryMissingField is a not-null violation.
+	CategoryRetryable        ErrorCategory = "retryable"         // CategoryRetryable is a serialization failure or deadlock.
+	CategoryValidation       ErrorCategory = "validation"        // CategoryValidation is a request body failing validation.
+)
+
+// categoryInfo is the HTTP status and client-facing message of a category.
+var categoryInfo = map[ErrorCategory]struct {
+	status  int
+	message string
+}{
+	CategoryInternal:         {http.StatusInternalServerError, "internal server error"},
+	CategoryNotFound:         {http.StatusNotFound, "resource not found"},
+	CategoryTenantNotFound:   {http.StatusNotFound, "tenant not found"},
+	CategoryTenantOffboarded: {http.StatusGone, "tenant is being offboarded"},
+	CategoryConflict:         {http.StatusConflict, "resource already exists"},
+	CategoryInvalidReference: {http.StatusUnprocessableEntity, "referenced resource does not exist"},
+	CategoryMissingField:     {http.StatusBadRequest, "a required field is missing"},
+	CategoryRetryable:        {http.StatusServiceUnavailable, "the request conflicted with another, please retry"},
+	CategoryValidation:       {http.StatusBadRequest, "request validation failed"},
+}
+
+// Status returns the HTTP status code for the category.
+func (c ErrorCategory) Status() int {
+	return categoryInfo[c].status
+}
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+var pgCategories = map[string]ErrorCategory{
+	"23505": CategoryConflict,         // unique_violation
+	"23503": CategoryInvalidReference, // foreign_key_violation
+	"23502": CategoryMissingField,     // not_null_violation
+	"40001": CategoryRetryable,        // serialization_failure
+	"40P01": CategoryRetryable,        // deadlock_detected
+	"3F000": CategoryTenantNotFound,   // invalid_schema_name, e.g. creating in an offboarded tenant
+}
+
+// MySQL error numbers, see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html.
+var mysqlCategories = map[uint16]ErrorCategory{
+	1062: CategoryConflict,         // ER_DUP_ENTRY
+	1451: CategoryInvalidReference, // ER_ROW_IS_REFERENCED_2
+	1452: CategoryInvalidReference, // ER_NO_REFERENCED_ROW_2
+	1048: CategoryMissingField,     // ER_BAD_NULL_ERROR
+	1364: CategoryMissingField,     // ER_NO_DEFAULT_FOR_FIELD
+	1213: CategoryRetryable,        // ER_LOCK_DEADLOCK
+	1205: CategoryRetryable,        // ER_LOCK_WAIT_TIMEOUT
+	1049: CategoryTenantNotFound,   // ER_BAD_DB_ERROR, e.g. an offboarded tenant
+}
+
+// classifyError maps a database or validation error to a portable category.
+func classifyError(err error) ErrorCategory {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		return CategoryValidation
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return CategoryNotFound
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return CategoryConflict
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if category, ok := pgCategories[pgErr.Code]; ok {
+			return category
+		}
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		if category, ok := mysqlCategories[mysqlErr.Number]; ok {
+			return category
+		}
+	}
+	return CategoryInternal
+}
+
+// isUndefinedTable reports whether err is a query of a table that does not
+// exist, which classifyError cannot tell a bug from a dropped tenant schema.
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "42P01" // undefined_table
+	}
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1146 // ER_NO_SUCH_TABLE
+}
+
+// classifyUndefinedTable classifies a query of a missing table made for the
+// request of c. Postgres reports a qualified query against a dropped schema
+// as 42P01, not 3F000, and only tenant schemas go away while the server
+// runs: if the request's tenant no longer has a row, it was not found; if
+// it still has a row but no longer a schema, it is being offboarded. Any
+// other missing table, shared or of a tenant whose schema is there, e.g.
+// after a failed migration, is an internal error.
+func (cr *controller) classifyUndefinedTable(c echo.Context) ErrorCategory {
+	tenantID, err := TenantFromContext(c)
+	if err != nil || cr.db == nil {
+		return CategoryInternal
+	}
+	// The response is still owed if the client went away.
+	ctx := context.WithoutCancel(c.Request().Context())
+	status, err := cr.tenantStatus(ctx, tenantID)
+	if err != nil {
+		return CategoryInternal
+	}
+	if status == "" {
+		return CategoryTenantNotFound
+	}
+	schemas, err := cr.tenantSchemas(ctx)
+	if err != nil || slices.Contains(schemas, tenantID) {
+		return CategoryInternal
+	}
+	return CategoryTenantOffboarded
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation,
+// translated by the dialector for drivers that classifyError does not know.
+func (cr *controller) isUniqueViolation(err error) bool {
+	if classifyError(err) == CategoryConflict {
+		return true
+	}
+	if t, ok := cr.db.Dialector.(gorm.ErrorTranslator); ok {
+		return errors.Is(t.Translate(err), gorm.ErrDuplicatedKey)
+	}
+	return false
+}
+
+// httpErrorHandler renders every error returned by a handler as an
+// [models.ErrorResponse]. Errors that are not [echo.HTTPError]s are
+// classified, so raw driver errors never reach the client.
+func (cr *controller) httpErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	var (
+		status int
+		res    models.ErrorResponse
+		he     *echo.HTTPError
+	)
+	if errors.As(err, &he) {
+		status = he.Code
+		if m, ok := he.Message.(string); ok {
+			res.Message = m
+		} else {
+			res.Message = fmt.Sprint(he.Message)
+		}
+	} else {
+		category := classifyError(err)
+		if category == CategoryInternal && isUndefinedTable(err) {
+			category = cr.classifyUndefinedTable(c)
+		}
+		if category == CategoryInternal {
+			log.Printf("Unhandled error: %v", err)
+		}
+		status = category.Status()
+		res.Message = categoryInfo[category].message
+		res.Category = string(category)
+		res.Fields = fieldErrors(err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = respond(c, status, res)
+	}
+	if err != nil {
+		log.Printf("Failed to write error response: %v", err)
+	}
+}
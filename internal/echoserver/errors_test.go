@@ -0,0 +1,188 @@
+package echoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/initdb"
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		category ErrorCategory
+		status   int
+	}{
+		{"RecordNotFound", gorm.ErrRecordNotFound, CategoryNotFound, http.StatusNotFound},
+		{"DuplicatedKey", gorm.ErrDuplicatedKey, CategoryConflict, http.StatusConflict},
+		{"PGUniqueViolation", &pgconn.PgError{Code: "23505"}, CategoryConflict, http.StatusConflict},
+		{"PGForeignKeyViolation", &pgconn.PgError{Code: "23503"}, CategoryInvalidReference, http.StatusUnprocessableEntity},
+		{"PGNotNullViolation", &pgconn.PgError{Code: "23502"}, CategoryMissingField, http.StatusBadRequest},
+		{"PGSerializationFailure", &pgconn.PgError{Code: "40001"}, CategoryRetryable, http.StatusServiceUnavailable},
+		{"PGUndefinedTable", &pgconn.PgError{Code: "42P01"}, CategoryInternal, http.StatusInternalServerError},
+		{"PGUndefinedSchema", &pgconn.PgError{Code: "3F000"}, CategoryTenantNotFound, http.StatusNotFound},
+		{"PGUnknownCode", &pgconn.PgError{Code: "XX000"}, CategoryInternal, http.StatusInternalServerError},
+		{"MySQLDuplicateEntry", &mysql.MySQLError{Number: 1062}, CategoryConflict, http.StatusConflict},
+		{"MySQLForeignKeyViolation", &mysql.MySQLError{Number: 1452}, CategoryInvalidReference, http.StatusUnprocessableEntity},
+		{"MySQLNotNullViolation", &mysql.MySQLError{Number: 1048}, CategoryMissingField, http.StatusBadRequest},
+		{"MySQLDeadlock", &mysql.MySQLError{Number: 1213}, CategoryRetryable, http.StatusServiceUnavailable},
+		{"MySQLNoSuchTable", &mysql.MySQLError{Number: 1146}, CategoryInternal, http.StatusInternalServerError},
+		{"MySQLUnknownDatabase", &mysql.MySQLError{Number: 1049}, CategoryTenantNotFound, http.StatusNotFound},
+		{"Wrapped", fmt.Errorf("create book: %w", &pgconn.PgError{Code: "23505"}), CategoryConflict, http.StatusConflict},
+		{"Unknown", errors.New("boom"), CategoryInternal, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			category := classifyError(tt.err)
+			assert.Equal(t, tt.category, category)
+			assert.Equal(t, tt.status, category.Status())
+		})
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	cr := &controller{}
+	e := echo.New()
+	cr.init(e)
+	e.GET("/tenants/driver-error", func(c echo.Context) error {
+		return &pgconn.PgError{Code: "23505", Message: `duplicate key value violates unique constraint "idx_tenants_domain_url"`}
+	})
+	e.GET("/tenants/missing-schema", func(c echo.Context) error {
+		return &pgconn.PgError{Code: "3F000", Message: `schema "tenant9" does not exist`}
+	})
+	e.GET("/tenants/http-error", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad input")
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   models.ErrorResponse
+	}{
+		{"/tenants/driver-error", http.StatusConflict, models.ErrorResponse{Message: "resource already exists", Category: "conflict"}},
+		{"/tenants/missing-schema", http.StatusNotFound, models.ErrorResponse{Message: "tenant not found", Category: "tenant_not_found"}},
+		{"/tenants/http-error", http.StatusBadRequest, models.ErrorResponse{Message: "bad input"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		assert.Equal(t, tt.status, rr.Code)
+		var body models.ErrorResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, tt.body, body)
+		assert.NotContains(t, rr.Body.String(), "idx_tenants_domain_url", "driver details must not leak")
+	}
+}
+
+func TestTenantOffboardedMidRequest(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	// The tenant is verified when the request starts; the books table then
+	// turns out to be missing, as Postgres reports it.
+	var offboard string
+	require.NoError(t, cr.db.Callback().Query().Before("gorm:query").Register("test:offboarded", func(db *gorm.DB) {
+		if db.Statement.Table == models.TableNameBook {
+			if offboard != "" {
+				require.NoError(t, cr.offboardTenant(context.Background(), offboard))
+			}
+			_ = db.AddError(&pgconn.PgError{Code: "42P01", Message: `relation "books" does not exist`})
+		}
+	}))
+
+	t.Run("SchemaDropped", func(t *testing.T) {
+		offboard = "tenant1"
+		rr := serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+		assert.Equal(t, http.StatusGone, rr.Code, rr.Body.String())
+		assert.Equal(t, models.ErrorResponse{Message: "tenant is being offboarded", Category: "tenant_offboarded"}, decode[models.ErrorResponse](t, rr))
+	})
+	t.Run("SchemaIntact", func(t *testing.T) {
+		offboard = ""
+		rr := serve(e, http.MethodGet, "/books", "tenant2.example.com", "")
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "a table missing from a live schema is a bug: %s", rr.Body.String())
+		assert.Equal(t, models.ErrorResponse{Message: "internal server error", Category: "internal"}, decode[models.ErrorResponse](t, rr))
+	})
+	t.Run("TenantDeleted", func(t *testing.T) {
+		offboard = "tenant3"
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant3.example.com"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodGet, "/books", "tenant3.example.com", "")
+		require.Equal(t, http.StatusGone, rr.Code, rr.Body.String())
+
+		// The registry still trusts the tenant after its row is gone too.
+		require.NoError(t, cr.db.Delete(&models.Tenant{}, "schema_name = ?", "tenant3").Error)
+		offboard = ""
+		rr = serve(e, http.MethodGet, "/books", "tenant3.example.com", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+		assert.Equal(t, models.ErrorResponse{Message: "tenant not found", Category: "tenant_not_found"}, decode[models.ErrorResponse](t, rr))
+	})
+	t.Run("NoTenant", func(t *testing.T) {
+		e.GET("/test/shared-table", func(c echo.Context) error {
+			return &pgconn.PgError{Code: "42P01", Message: `relation "public.book_counters" does not exist`}
+		})
+		rr := serve(e, http.MethodGet, "/test/shared-table", "", "")
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "a missing shared table is a bug: %s", rr.Body.String())
+	})
+}
+
+// TestMissingSchemaPostgres checks the classification of the errors Postgres
+// itself reports for a dropped tenant schema. It needs Docker.
+func TestMissingSchemaPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("needs a Postgres container")
+	}
+	ctx := context.Background()
+	db, cleanup, err := initdb.Connect(ctx, "postgres")
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	require.NoError(t, db.RegisterModels(ctx, &models.Tenant{}, &models.BookCounter{}, &models.Book{}, &models.Tag{}))
+	require.NoError(t, db.MigrateSharedModels(ctx))
+	cr, e := newServer(db)
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		// Cache the tenant, so it is still verified once its schema is gone.
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	t.Run("DriverCode", func(t *testing.T) {
+		require.NoError(t, cr.db.Exec("DROP SCHEMA tenant1 CASCADE").Error)
+		err := cr.db.Table("tenant1." + models.TableNameBook).Take(&models.Book{}).Error
+		var pgErr *pgconn.PgError
+		require.ErrorAs(t, err, &pgErr)
+		assert.Equal(t, "42P01", pgErr.Code, "a qualified query of a dropped schema is an undefined table")
+	})
+	t.Run("SchemaDropped", func(t *testing.T) {
+		rr := serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+		assert.Equal(t, http.StatusGone, rr.Code, rr.Body.String())
+		assert.Equal(t, "tenant_offboarded", decode[models.ErrorResponse](t, rr).Category)
+	})
+	t.Run("TenantDeleted", func(t *testing.T) {
+		require.NoError(t, cr.db.Delete(&models.Tenant{}, "schema_name = ?", "tenant1").Error)
+		rr := serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+		assert.Equal(t, "tenant_not_found", decode[models.ErrorResponse](t, rr).Category)
+	})
+	t.Run("SchemaIntact", func(t *testing.T) {
+		rr := serve(e, http.MethodGet, "/books", "tenant2.example.com", "")
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	})
+}
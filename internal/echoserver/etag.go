@@ -0,0 +1,93 @@
+package echoserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/labstack/echo/v4"
+)
+
+// bookETag returns a weak entity tag identifying the version of a book.
+func bookETag(tenantID string, book *models.Book) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s/%d/%d", tenantID, book.ID, book.UpdatedAt.UnixNano()))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison required for GET.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (cr *controller) getBookHandler(c echo.Context) error {
+	tenantID, err := mustTenant(c)
+	if err != nil {
+		return err
+	}
+	db, err := cr.tenantDB(c.Request().Context(), tenantID)
+	if err != nil {
+		return err
+	}
+	bookID, err := bookIDParam(c)
+	if err != nil {
+		return err
+	}
+	var book models.Book
+	if err = db.Scopes(scopes.WithTenantSchema(tenantID)).First(&book, bookID).Error; err != nil {
+		return err
+	}
+	etag := bookETag(tenantID, &book)
+	c.Response().Header().Set("ETag", etag)
+	if inm := c.Request().Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return cr.respondProjected(c, tenantID, http.StatusOK, &models.BookResponse{
+		ID:      book.ID,
+		Name:    book.Name,
+		Author:  book.Author,
+		ISBN:    book.ISBN,
+		Version: book.Version,
+	})
+}
+
+// booksLastModified returns when the tenant's books last changed, counting
+// deletions, or the zero time if there are none.
+func (cr *controller) booksLastModified(c echo.Context, tenantID string) (time.Time, error) {
+	db, err := cr.tenantDB(c.Request().Context(), tenantID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var lastModified time.Time
+	for _, column := range []string{"updated_at", "deleted_at"} {
+		var times []time.Time
+		if err := db.Table(models.TableNameBook).
+			Scopes(scopes.WithTenantSchema(tenantID)).
+			Where(column+" IS NOT NULL").Order(column+" DESC").Limit(1).
+			Pluck(column, &times).Error; err != nil {
+			return time.Time{}, err
+		}
+		if len(times) > 0 && times[0].After(lastModified) {
+			lastModified = times[0]
+		}
+	}
+	return lastModified, nil
+}
+
+// notModifiedSince reports whether an If-Modified-Since header is at or after
+// lastModified, at the one second resolution of HTTP dates.
+func notModifiedSince(ifModifiedSince string, lastModified time.Time) bool {
+	since, err := http.ParseTime(ifModifiedSince)
+	return err == nil && !lastModified.Truncate(time.Second).After(since)
+}
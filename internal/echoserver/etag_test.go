@@ -0,0 +1,85 @@
+package echoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `W/"abc"`
+	for header, want := range map[string]bool{
+		`W/"abc"`:          true,
+		`"abc"`:            true,
+		`W/"xyz", W/"abc"`: true,
+		`*`:                true,
+		`W/"xyz"`:          false,
+	} {
+		assert.Equal(t, want, etagMatches(header, etag), header)
+	}
+}
+
+func TestConditionalBookReads(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", host, `{"name": "Book 1", "author": "Author 1"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	get := func(path string, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("ETag", func(t *testing.T) {
+		rr := get("/books/1", "", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.JSONEq(t, `{"id": 1, "name": "Book 1", "author": "Author 1", "version": 1}`, rr.Body.String())
+		etag := rr.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		rr = get("/books/1", "If-None-Match", etag)
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Body.String())
+
+		time.Sleep(5 * time.Millisecond)
+		rr = serve(e, http.MethodPut, "/books/1", host, `{"name": "Book 1 - Updated", "version": 1}`)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		rr = get("/books/1", "If-None-Match", etag)
+		assert.Equal(t, http.StatusOK, rr.Code, "changed books are served again")
+		assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+		assert.JSONEq(t, `{"id": 1, "name": "Book 1 - Updated", "author": "Author 1", "version": 2}`, rr.Body.String())
+
+		rr = get("/books/9", "", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("IfModifiedSince", func(t *testing.T) {
+		rr := get("/books", "", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		lastModified := rr.Header().Get(echo.HeaderLastModified)
+		require.NotEmpty(t, lastModified)
+
+		rr = get("/books", echo.HeaderIfModifiedSince, lastModified)
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+
+		require.NoError(t, cr.db.Table(models.TableNameBook).Scopes(scopes.WithTenantSchema("tenant1")).
+			Where("id = ?", 1).UpdateColumn("updated_at", time.Now().Add(2*time.Second)).Error)
+		rr = get("/books", echo.HeaderIfModifiedSince, lastModified)
+		assert.Equal(t, http.StatusOK, rr.Code, "changed lists are served again")
+	})
+}
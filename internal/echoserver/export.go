@@ -0,0 +1,138 @@
+package echoserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/labstack/echo/v4"
+)
+
+// tenantTables are the tables of a tenant schema included in exports.
+var tenantTables = []string{models.TableNameBook, models.TableNameTag}
+
+// TenantExport is the archive of a tenant's data, written as JSON.
+type TenantExport struct {
+	Tenant     models.TenantResponse       `json:"tenant"`
+	Schema     string                      `json:"schema"`
+	ExportedAt time.Time                   `json:"exportedAt"`
+	Tables     map[string][]map[string]any `json:"tables"` // Tables maps table names to their rows, soft-deleted ones included.
+}
+
+// TenantExporter stores tenant exports, e.g. before a tenant is offboarded.
+type TenantExporter interface {
+	// Export stores the export of the tenant with the given schema, read
+	// from r. It must only return nil once the export is durably stored.
+	Export(ctx context.Context, schemaName string, r io.Reader) error
+}
+
+// DirExporter is a [TenantExporter] writing each export to a file named
+// after the schema and export time in a directory.
+type DirExporter struct {
+	Dir string
+}
+
+var _ TenantExporter = DirExporter{}
+
+// Export implements [TenantExporter]. The file is written under a temporary
+// name and renamed once complete, so a partial export is never mistaken for
+// a finished one.
+func (x DirExporter) Export(_ context.Context, schemaName string, r io.Reader) error {
+	if err := os.MkdirAll(x.Dir, 0o750); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(x.Dir, schemaName+"-*.json.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if _, err = io.Copy(f, r); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.json", schemaName, time.Now().UTC().Format("20060102T150405.000000000Z"))
+	return os.Rename(f.Name(), filepath.Join(x.Dir, name))
+}
+
+// errNoExporter returns the error of archiving without a configured
+// [Options.Exporter].
+func errNoExporter() error {
+	return echo.NewHTTPError(http.StatusNotImplemented, "no tenant exporter configured")
+}
+
+// exportTenant reads the tenant's tables in one transaction and hands the
+// export to the configured exporter.
+func (cr *controller) exportTenant(ctx context.Context, tenant *models.Tenant) (*TenantExport, error) {
+	if cr.opts.Exporter == nil {
+		return nil, errNoExporter()
+	}
+	export := &TenantExport{
+		Tenant: models.TenantResponse{
+			ID:        tenant.ID,
+			DomainURL: tenant.DomainURL,
+			Tier:      tenant.Tier,
+		},
+		Schema:     tenant.SchemaName,
+		ExportedAt: time.Now().UTC(),
+		Tables:     make(map[string][]map[string]any, len(tenantTables)),
+	}
+	if err := cr.withTenantTx(ctx, tenant.SchemaName, func(tx *multitenancy.DB) error {
+		for _, table := range tenantTables {
+			rows := []map[string]any{}
+			if err := tx.Table(table).Order("id").Find(&rows).Error; err != nil {
+				return err
+			}
+			export.Tables[table] = rows
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(export); err != nil {
+		return nil, err
+	}
+	if err := cr.opts.Exporter.Export(ctx, tenant.SchemaName, &buf); err != nil {
+		return nil, fmt.Errorf("export tenant %q: %w", tenant.SchemaName, err)
+	}
+	return export, nil
+}
+
+// exportTenantHandler archives a tenant's data without offboarding it.
+func (cr *controller) exportTenantHandler(c echo.Context) error {
+	id, err := tenantIDParam(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	tenant := &models.Tenant{}
+	if err = cr.db.WithContext(ctx).First(tenant, id).Error; err != nil {
+		return err
+	}
+	export, err := cr.exportTenant(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	res := models.ExportTenantResponse{
+		Schema:     export.Schema,
+		ExportedAt: export.ExportedAt,
+		Rows:       make(map[string]int, len(export.Tables)),
+	}
+	for table, rows := range export.Tables {
+		res.Rows[table] = len(rows)
+	}
+	return respond(c, http.StatusOK, res)
+}
@@ -0,0 +1,145 @@
+package echoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memExporter keeps exports in memory, optionally failing them.
+type memExporter struct {
+	mu      sync.Mutex
+	exports map[string][]byte
+	err     error
+}
+
+func (x *memExporter) Export(_ context.Context, schemaName string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.err != nil {
+		return x.err
+	}
+	if x.exports == nil {
+		x.exports = make(map[string][]byte)
+	}
+	x.exports[schemaName] = b
+	return nil
+}
+
+func (x *memExporter) get(t *testing.T, schemaName string) TenantExport {
+	t.Helper()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	b, ok := x.exports[schemaName]
+	require.True(t, ok, "tenant %q is exported", schemaName)
+	var export TenantExport
+	require.NoError(t, json.Unmarshal(b, &export))
+	return export
+}
+
+const exportToken = "admin-secret"
+
+// newExportServer starts a server exporting to x, with a tenant owning one
+// book.
+func newExportServer(t *testing.T, x TenantExporter) (*controller, *echo.Echo) {
+	t.Helper()
+	cr, e := newSQLiteServer(t, WithAdminToken(exportToken), WithTenantExporter(x))
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", "tenant1.example.com", `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	return cr, e
+}
+
+func TestExportTenant(t *testing.T) {
+	x := &memExporter{}
+	_, e := newExportServer(t, x)
+
+	rr := serveAdmin(e, http.MethodPost, "/tenants/1/export", exportToken)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	res := decode[models.ExportTenantResponse](t, rr)
+	assert.Equal(t, "tenant1", res.Schema)
+	assert.Equal(t, map[string]int{models.TableNameBook: 1, models.TableNameTag: 0}, res.Rows)
+
+	export := x.get(t, "tenant1")
+	assert.Equal(t, "tenant1.example.com", export.Tenant.DomainURL)
+	require.Len(t, export.Tables[models.TableNameBook], 1)
+	assert.Equal(t, "Dune", export.Tables[models.TableNameBook][0]["name"])
+
+	rr = serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "tenant is kept: %s", rr.Body.String())
+	rr = serveAdmin(e, http.MethodPost, "/tenants/1/export", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = serveAdmin(e, http.MethodPost, "/tenants/1%20OR%201=1/export", exportToken)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestArchiveOffboard(t *testing.T) {
+	x := &memExporter{}
+	cr, e := newExportServer(t, x)
+
+	rr := serve(e, http.MethodDelete, "/tenants/1?archive=true&confirm=true", "", "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Len(t, x.get(t, "tenant1").Tables[models.TableNameBook], 1)
+	schemas, err := cr.tenantSchemas(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, schemas, "tenant1", "tenant schema is dropped")
+	assert.ErrorIs(t, cr.db.First(&models.Tenant{}, 1).Error, gorm.ErrRecordNotFound, "tenant row is deleted")
+}
+
+func TestArchiveOffboardExportFailure(t *testing.T) {
+	x := &memExporter{err: errors.New("bucket unavailable")}
+	cr, e := newExportServer(t, x)
+
+	rr := serve(e, http.MethodDelete, "/tenants/1?archive=true&confirm=true", "", "")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, rr.Body.String())
+	schemas, err := cr.tenantSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, schemas, "tenant1", "tenant schema is kept")
+	rr = serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestArchiveWithoutExporter(t *testing.T) {
+	_, e := newSQLiteServer(t, WithAdminToken(exportToken))
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = serve(e, http.MethodDelete, "/tenants/1?archive=true&confirm=true", "", "")
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+	rr = serveAdmin(e, http.MethodPost, "/tenants/1/export", exportToken)
+	assert.Equal(t, http.StatusNotImplemented, rr.Code)
+	rr = serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "tenant is kept: %s", rr.Body.String())
+}
+
+func TestDirExporter(t *testing.T) {
+	dir := t.TempDir()
+	x := DirExporter{Dir: dir}
+	require.NoError(t, x.Export(context.Background(), "tenant1", strings.NewReader(`{"schema":"tenant1"}`)))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "only the finished export remains")
+	name := entries[0].Name()
+	assert.True(t, strings.HasPrefix(name, "tenant1-") && strings.HasSuffix(name, ".json"), name)
+	b, err := os.ReadFile(dir + "/" + name)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"schema":"tenant1"}`, string(b))
+}
@@ -0,0 +1,32 @@
+package echoserver
+
+import (
+	"net/http"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
+)
+
+// getFlatBooksHandler serves the flat view of the tenant's books for
+// reporting clients: books joined with their tags into a single row per tag,
+// fetched with one query. Books without tags yield a single row without one.
+func (cr *controller) getFlatBooksHandler(c echo.Context, tenantID string) error {
+	tags := clause.Table{Name: tenantID + "." + models.TableNameTag, Alias: "t"}
+	db, err := cr.tenantDB(c.Request().Context(), tenantID)
+	if err != nil {
+		return err
+	}
+	var rows []models.FlatBookResponse
+	if err := db.Table(models.TableNameBook).
+		Scopes(scopes.WithTenantSchema(tenantID)).
+		Select("books.id AS book_id, books.name, books.author, books.isbn, t.name AS tag").
+		Joins("LEFT JOIN ? ON t.book_id = books.id AND t.deleted_at IS NULL", tags).
+		Where("books.deleted_at IS NULL").
+		Order("books.id, t.name").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	return cr.respondProjected(c, tenantID, http.StatusOK, rows)
+}
@@ -0,0 +1,45 @@
+package echoserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlatBooks(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host1, host2 = "tenant1.example.com", "tenant2.example.com"
+	for _, host := range []string{host1, host2} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	for host, body := range map[string]string{
+		host1: `{"name": "Book 1", "author": "Author 1", "isbn": "9780306406157", "tags": [{"name": "go"}, {"name": "databases"}]}`,
+		host2: `{"name": "Book 1", "author": "Author 2", "tags": [{"name": "fiction"}]}`,
+	} {
+		rr := serve(e, http.MethodPost, "/books", host, body)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	rr := serve(e, http.MethodPost, "/books", host1, `{"name": "Book 2", "author": "Author 1"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	flat := func(host string) []models.FlatBookResponse {
+		rr := serve(e, http.MethodGet, "/books?view=flat", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		return decode[[]models.FlatBookResponse](t, rr)
+	}
+	assert.Equal(t, []models.FlatBookResponse{
+		{BookID: 1, Name: "Book 1", Author: "Author 1", ISBN: "9780306406157", Tag: "databases"},
+		{BookID: 1, Name: "Book 1", Author: "Author 1", ISBN: "9780306406157", Tag: "go"},
+		{BookID: 2, Name: "Book 2", Author: "Author 1"},
+	}, flat(host1))
+	assert.Equal(t, []models.FlatBookResponse{
+		{BookID: 1, Name: "Book 1", Author: "Author 2", Tag: "fiction"},
+	}, flat(host2), "tenants are isolated")
+
+	rr = serve(e, http.MethodGet, "/books?view=tree", host1, "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,40 @@
+package echoserver
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Health probe paths. They are served without a tenant and are never
+// throttled, so probes succeed under load.
+const (
+	healthPath    = "/healthz"
+	readinessPath = "/readyz"
+)
+
+// isHealthPath reports whether path is a health probe.
+func isHealthPath(path string) bool {
+	return path == healthPath || path == readinessPath
+}
+
+// healthHandler reports that the server is alive.
+func healthHandler(c echo.Context) error {
+	return respond(c, http.StatusOK, models.HealthResponse{Status: "ok"})
+}
+
+// readinessHandler reports whether the server can serve requests, that is
+// whether the database is reachable.
+func (cr *controller) readinessHandler(c echo.Context) error {
+	sqlDB, err := cr.db.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(c.Request().Context())
+	}
+	if err != nil {
+		log.Printf("Readiness check failed: %v", err)
+		return respond(c, http.StatusServiceUnavailable, models.HealthResponse{Status: "unavailable"})
+	}
+	return respond(c, http.StatusOK, models.HealthResponse{Status: "ok"})
+}
@@ -0,0 +1,27 @@
+package echoserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	for _, path := range []string{healthPath, readinessPath} {
+		rr := serve(e, http.MethodGet, path, "unknown.example.com", "")
+		assert.Equal(t, http.StatusOK, rr.Code, "%s is served without a tenant: %s", path, rr.Body.String())
+	}
+
+	sqlDB, err := cr.db.DB.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	rr := serve(e, http.MethodGet, healthPath, "", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = serve(e, http.MethodGet, readinessPath, "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.JSONEq(t, `{"status": "unavailable"}`, rr.Body.String())
+}
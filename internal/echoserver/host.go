@@ -0,0 +1,58 @@
+package echoserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxHostLength bounds the Host header: a 253 byte domain name plus a port.
+const maxHostLength = 253 + len(":65535")
+
+// validateHost returns a middleware rejecting requests whose Host header is
+// over-long or not a valid host name, before any tenant is resolved from it.
+func validateHost() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if host := c.Request().Host; host != "" && !validHost(host) {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid host header")
+			}
+			return next(c)
+		}
+	}
+}
+
+// validHost reports whether host is a domain name or IP address with an
+// optional port.
+func validHost(host string) bool {
+	if len(host) > maxHostLength {
+		return false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	} else if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return false
+	}
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
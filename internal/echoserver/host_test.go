@@ -0,0 +1,60 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"tenant1.example.com":                     true,
+		"tenant1.example.com:8080":                true,
+		"tenant1.example.com.":                    true,
+		"localhost":                               true,
+		"192.0.2.1:8080":                          true,
+		"[2001:db8::1]:8080":                      true,
+		"[2001:db8::1]":                           true,
+		"tenant1..example.com":                    false,
+		"-tenant1.example.com":                    false,
+		"tenant1.example.com:8080:9090":           false,
+		"tenant 1.example.com":                    false,
+		"tenant1.example.com/evil":                false,
+		strings.Repeat("a", 64) + ".example.com":  false,
+		strings.Repeat("a.", 200) + "example.com": false,
+	} {
+		assert.Equal(t, want, validHost(host), host)
+	}
+}
+
+func TestValidateHost(t *testing.T) {
+	var lookups int
+	cr := &controller{tenants: NewTenantRegistry(0, func(ctx context.Context, schemaName string) (bool, error) {
+		lookups++
+		return true, nil
+	})}
+	e := echo.New()
+	cr.init(e)
+	e.GET("/ping", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	for host, status := range map[string]int{
+		"tenant1.example.com":                    http.StatusOK,
+		strings.Repeat("tenant1", 1000) + ".com": http.StatusBadRequest,
+		"tenant1.exa%mple.com":                   http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code, host)
+		if status == http.StatusBadRequest {
+			assert.JSONEq(t, `{"message": "invalid host header"}`, rr.Body.String())
+		}
+	}
+	assert.Equal(t, 1, lookups, "invalid hosts are rejected before any lookup")
+}
@@ -0,0 +1,250 @@
+package echoserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderIdempotencyKey identifies a request a client may retry, so that
+// retrying it does not repeat its effect.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed is set on a response replayed for a repeated
+// [HeaderIdempotencyKey].
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+// defaultIdempotencyTTL is how long responses are kept for replay by default.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyClaimTTL is how long a key claimed by a request that has not
+// responded yet is held, so a request that never finishes does not hold its
+// key forever.
+const idempotencyClaimTTL = 5 * time.Minute
+
+// idempotencySweepInterval is how often expired entries are swept, so that
+// claiming a key does not cost a pass over every entry.
+const idempotencySweepInterval = time.Minute
+
+// maxIdempotencyKeyLength bounds the keys clients may send.
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers recorded for replay. Others, such
+// as the request ID, describe the request they were sent for.
+var replayedHeaders = []string{echo.HeaderContentType, echo.HeaderLocation, "ETag", HeaderXAffectedRows}
+
+// idempotentResponse is a response recorded for an idempotency key.
+type idempotentResponse struct {
+	hash    [sha256.Size]byte // hash identifies the request the key was first used with.
+	done    bool              // done reports whether the response is recorded, rather than still being produced.
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time // expires is when the response, or the claim while it is being produced, is forgotten.
+}
+
+// idempotencyStore records the responses of requests sent with an
+// idempotency key, so that repeating them replays the response instead of
+// repeating their effect.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotentResponse
+	nextSweep time.Time // nextSweep is when expired entries are next swept.
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+}
+
+var _ Cache = (*idempotencyStore)(nil)
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{entries: make(map[string]*idempotentResponse)}
+}
+
+// Stats implements [Cache].
+func (s *idempotencyStore) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return CacheStats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+		Size:   len(s.entries),
+	}
+}
+
+// Clear implements [Cache].
+func (s *idempotencyStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.entries)
+}
+
+// claim returns the response recorded for key, or claims key for a request
+// with the given hash and returns nil if there is none. Expired entries are
+// swept at most once per [idempotencySweepInterval].
+func (s *idempotencyStore) claim(key string, hash [sha256.Size]byte, now time.Time) *idempotentResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !now.Before(s.nextSweep) {
+		for k, r := range s.entries {
+			if now.After(r.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(idempotencySweepInterval)
+	}
+	if r, ok := s.entries[key]; ok && !now.After(r.expires) {
+		s.hits.Add(1)
+		res := *r
+		return &res
+	}
+	s.misses.Add(1)
+	s.entries[key] = &idempotentResponse{hash: hash, expires: now.Add(idempotencyClaimTTL)}
+	return nil
+}
+
+// record stores the response of the request that claimed key.
+func (s *idempotencyStore) record(key string, r *idempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.done = true
+	s.entries[key] = r
+}
+
+// release forgets key, so that the request can be retried.
+func (s *idempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// idempotent returns a middleware replaying the response of a request whose
+// [HeaderIdempotencyKey] was used before, for [Options.IdempotencyTTL].
+// Reusing a key for a different request fails with 422 rather than
+// replaying a response that does not belong to it. Only successful
+// responses are recorded, so a failed request can be retried with the same
+// key. A replayed 201 keeps its status, so the replay is indistinguishable
+// from the original response, unless [Options.ReplayCreatedAsOK] is set.
+func (cr *controller) idempotent() echo.MiddlewareFunc {
+	ttl := cr.opts.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest, "idempotency key is too long")
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Keys are scoped to the tenant, so tenants cannot observe each
+			// other's responses by guessing keys.
+			tenantID, _ := TenantFromContext(c)
+			key = tenantID + "\x00" + key
+			hash := requestHash(req.Method, req.URL.RequestURI(), body)
+
+			prev := cr.idempotency.claim(key, hash, time.Now())
+			switch {
+			case prev == nil:
+			case prev.hash != hash:
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, "idempotency key reused with different payload")
+			case !prev.done:
+				return echo.NewHTTPError(http.StatusConflict, "a request with this idempotency key is in progress")
+			default:
+				h := c.Response().Header()
+				for k, v := range prev.header {
+					h[k] = v
+				}
+				h.Set(HeaderIdempotentReplayed, "true")
+				status := prev.status
+				if status == http.StatusCreated && cr.opts.ReplayCreatedAsOK {
+					status = http.StatusOK // nothing was created this time
+				}
+				return c.Blob(status, h.Get(echo.HeaderContentType), prev.body)
+			}
+
+			// Unless a response is recorded, the key is released however the
+			// handler ends, panics included, so the request can be retried.
+			recorded := false
+			defer func() {
+				if !recorded {
+					cr.idempotency.release(key)
+				}
+			}()
+			rec := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = rec
+			defer func() { c.Response().Writer = rec.ResponseWriter }()
+			if err = next(c); err != nil {
+				return err
+			}
+			status := c.Response().Status
+			if status < 200 || status >= 300 {
+				return nil
+			}
+			recorded = true
+			cr.idempotency.record(key, &idempotentResponse{
+				hash:    hash,
+				status:  status,
+				header:  recordedHeader(c.Response().Header()),
+				body:    rec.body.Bytes(),
+				expires: time.Now().Add(ttl),
+			})
+			return nil
+		}
+	}
+}
+
+func recordedHeader(h http.Header) http.Header {
+	rec := make(http.Header, len(replayedHeaders))
+	for _, k := range replayedHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			rec[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	return rec
+}
+
+// requestHash identifies a request by its method, URI and body.
+func requestHash(method, uri string, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	io.WriteString(h, method+"\n"+uri+"\n")
+	h.Write(body)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// responseRecorder copies the body written to a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
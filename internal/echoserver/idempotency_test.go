@@ -0,0 +1,177 @@
+package echoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	post := func(host, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(HeaderIdempotencyKey, key)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+	countBooks := func() int64 {
+		var n int64
+		require.NoError(t, cr.db.Model(&models.Book{}).Count(&n).Error)
+		return n
+	}
+	const dune = `{"name": "Dune", "author": "Frank Herbert"}`
+
+	first := post(host, "key-1", dune)
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+	assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+
+	t.Run("SamePayloadReplaysResponse", func(t *testing.T) {
+		rr := post(host, "key-1", dune)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.Equal(t, "true", rr.Header().Get(HeaderIdempotentReplayed))
+		assert.JSONEq(t, first.Body.String(), rr.Body.String())
+		assert.Equal(t, first.Header().Get(echo.HeaderContentType), rr.Header().Get(echo.HeaderContentType))
+		assert.NotEqual(t, first.Header().Get(echo.HeaderXRequestID), rr.Header().Get(echo.HeaderXRequestID),
+			"the request ID is not replayed")
+		assert.EqualValues(t, 1, countBooks(), "the book is created once")
+	})
+
+	t.Run("DifferentPayloadIsRejected", func(t *testing.T) {
+		rr := post(host, "key-1", `{"name": "Dune Messiah", "author": "Frank Herbert"}`)
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+		assert.Equal(t, "idempotency key reused with different payload", decode[models.ErrorResponse](t, rr).Message)
+		assert.EqualValues(t, 1, countBooks())
+	})
+
+	t.Run("FailuresAreNotRecorded", func(t *testing.T) {
+		rr := post(host, "key-2", `{"name": "Dune"}`)
+		require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+		rr = post(host, "key-2", `{"name": "Dune Messiah", "author": "Frank Herbert"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, "the key can be reused after a failure: %s", rr.Body.String())
+		assert.Empty(t, rr.Header().Get(HeaderIdempotentReplayed))
+	})
+
+	t.Run("KeysAreScopedToTheTenant", func(t *testing.T) {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant2.example.com"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = post("tenant2.example.com", "key-1", `{"name": "Emma", "author": "Jane Austen"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.Empty(t, rr.Header().Get(HeaderIdempotentReplayed))
+	})
+
+	t.Run("WithoutKey", func(t *testing.T) {
+		before := countBooks()
+		rr := serve(e, http.MethodPost, "/books", host, dune)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.Equal(t, before+1, countBooks())
+	})
+}
+
+func TestIdempotencyStoreExpires(t *testing.T) {
+	s := newIdempotencyStore()
+	hash := requestHash(http.MethodPost, "/books", []byte("{}"))
+	now := time.Now()
+	require.Nil(t, s.claim("k", hash, now))
+	s.record("k", &idempotentResponse{hash: hash, status: http.StatusCreated, expires: now.Add(time.Minute)})
+
+	prev := s.claim("k", hash, now.Add(time.Second))
+	require.NotNil(t, prev)
+	assert.Equal(t, http.StatusCreated, prev.status)
+	assert.Nil(t, s.claim("k", hash, now.Add(2*time.Minute)), "an expired key is claimed anew")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Size: 1}, s.Stats())
+}
+
+func TestIdempotencyStoreClaimExpires(t *testing.T) {
+	s := newIdempotencyStore()
+	hash := requestHash(http.MethodPost, "/books", []byte("{}"))
+	now := time.Now()
+	require.Nil(t, s.claim("k", hash, now))
+	prev := s.claim("k", hash, now.Add(time.Second))
+	require.NotNil(t, prev)
+	assert.False(t, prev.done, "the key is in progress")
+	assert.Nil(t, s.claim("k", hash, now.Add(idempotencyClaimTTL+time.Second)), "an abandoned claim is claimed anew")
+}
+
+func TestIdempotencyStoreSweep(t *testing.T) {
+	s := newIdempotencyStore()
+	hash := requestHash(http.MethodPost, "/books", []byte("{}"))
+	now := time.Now()
+	require.Nil(t, s.claim("a", hash, now))
+	s.record("a", &idempotentResponse{hash: hash, status: http.StatusCreated, expires: now.Add(time.Second)})
+
+	require.Nil(t, s.claim("b", hash, now.Add(2*time.Second)))
+	assert.Equal(t, 2, s.Stats().Size, "expired entries are kept until the next sweep")
+	require.Nil(t, s.claim("c", hash, now.Add(idempotencySweepInterval)))
+	assert.Equal(t, 2, s.Stats().Size, "the expired entry is swept")
+}
+
+func TestIdempotentPanicReleasesKey(t *testing.T) {
+	cr := &controller{tenants: staticTenants("tenant1")}
+	e := echo.New()
+	cr.init(e)
+	var panics bool
+	e.POST("/panic", func(c echo.Context) error {
+		if panics {
+			panic("boom")
+		}
+		return c.NoContent(http.StatusCreated)
+	}, cr.idempotent())
+	post := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/panic", nil)
+		req.Header.Set(HeaderIdempotencyKey, "key-1")
+		req.Host = "tenant1.example.com"
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	panics = true
+	require.Equal(t, http.StatusInternalServerError, post())
+	panics = false
+	assert.Equal(t, http.StatusCreated, post(), "the key is not left in progress")
+}
+
+func TestIdempotentReplayStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{name: "OriginalStatus", want: http.StatusCreated},
+		{name: "OK", opts: []Option{WithReplayCreatedAsOK()}, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, e := newSQLiteServer(t, tt.opts...)
+			create := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/tenants", strings.NewReader(`{"domainUrl": "tenant1.example.com"}`))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				req.Header.Set(HeaderIdempotencyKey, "create-tenant1")
+				rr := httptest.NewRecorder()
+				e.ServeHTTP(rr, req)
+				return rr
+			}
+			first := create()
+			require.Equal(t, http.StatusCreated, first.Code, "the original create: %s", first.Body.String())
+
+			rr := create()
+			assert.Equal(t, tt.want, rr.Code, rr.Body.String())
+			assert.Equal(t, "true", rr.Header().Get(HeaderIdempotentReplayed))
+			assert.JSONEq(t, first.Body.String(), rr.Body.String(), "the original tenant is returned")
+		})
+	}
+}
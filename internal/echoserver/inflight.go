@@ -0,0 +1,34 @@
+package echoserver
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// inFlightRetryAfter is the Retry-After, in seconds, of requests rejected
+// for exceeding [Options.MaxInFlight].
+const inFlightRetryAfter = "1"
+
+// limitInFlight returns a middleware serving at most max requests at once.
+// Requests beyond the limit are rejected with 503 straight away rather than
+// queued, so a load spike cannot pile up goroutines and database
+// connections. Health probes are never rejected.
+func (cr *controller) limitInFlight(max int) echo.MiddlewareFunc {
+	sem := make(chan struct{}, max)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if path, ok := cr.routePath(c.Request().URL.Path); ok && isHealthPath(path) {
+				return next(c)
+			}
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				return next(c)
+			default:
+				c.Response().Header().Set(echo.HeaderRetryAfter, inFlightRetryAfter)
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "server is busy, please retry")
+			}
+		}
+	}
+}
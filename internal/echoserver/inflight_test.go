@@ -0,0 +1,67 @@
+package echoserver
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitInFlight(t *testing.T) {
+	const max, requests = 2, 10
+	_, e := newSQLiteServer(t, WithMaxInFlight(max))
+	entered, release := make(chan struct{}, requests), make(chan struct{})
+	e.GET("/admin/slow", func(c echo.Context) error {
+		entered <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	var wg sync.WaitGroup
+	codes := make(chan int, requests)
+	for range max {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(e, http.MethodGet, "/admin/slow", "", "").Code
+		}()
+	}
+	for range max {
+		<-entered
+	}
+
+	// The server is saturated: further requests are rejected straight away,
+	// and probes still succeed.
+	for range requests - max {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := serve(e, http.MethodGet, "/admin/slow", "", "")
+			if rr.Code == http.StatusServiceUnavailable {
+				assert.Equal(t, "1", rr.Header().Get(echo.HeaderRetryAfter))
+			}
+			codes <- rr.Code
+		}()
+	}
+	for _, path := range []string{healthPath, readinessPath} {
+		rr := serve(e, http.MethodGet, path, "", "")
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+		assert.JSONEq(t, `{"status": "ok"}`, rr.Body.String(), path)
+	}
+
+	for range requests - max {
+		require.Equal(t, http.StatusServiceUnavailable, <-codes)
+	}
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		assert.Equal(t, http.StatusNoContent, code, "requests within the limit are served")
+	}
+
+	rr := serve(e, http.MethodGet, "/admin/slow", "", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code, "slots are released")
+}
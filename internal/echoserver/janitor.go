@@ -0,0 +1,73 @@
+package echoserver
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+)
+
+// janitorLoop runs a janitor pass every [Options.JanitorInterval] until ctx
+// is done. Passes run one after the other, so they never overlap.
+func (cr *controller) janitorLoop(ctx context.Context) {
+	ticker := time.NewTicker(cr.opts.JanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cr.janitorPass(ctx)
+	}
+}
+
+// janitorPass cleans up what a partially failed offboarding left behind:
+// it drops the schemas of the shared database no tenant row points to, and
+// deletes the rows of onboarded tenants whose schema is missing, as
+// offboarding them would have. Rows younger than [Options.JanitorInterval]
+// are left alone, since their tenant may still be onboarding. A pass is
+// skipped while drift is being fixed by another.
+func (cr *controller) janitorPass(ctx context.Context) {
+	if !cr.reconciling.TryLock() {
+		log.Printf("Janitor: skipping pass, a reconciliation is in progress")
+		return
+	}
+	defer cr.reconciling.Unlock()
+
+	orphaned, missing, err := cr.findDrift(ctx)
+	if err != nil {
+		log.Printf("Janitor: failed to find drift: %v", err)
+		return
+	}
+	for _, schema := range orphaned {
+		log.Printf("Janitor: dropping orphaned schema %q", schema)
+	}
+	dropped, failed := cr.dropOrphanedSchemas(ctx, orphaned)
+	if len(dropped) > 0 || len(failed) > 0 {
+		log.Printf("Janitor: dropped %d orphaned schemas, %d failed", len(dropped), len(failed))
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	var stale []models.Tenant
+	if err = cr.db.WithContext(ctx).
+		Where("schema_name IN ? AND created_at < ?", missing, time.Now().Add(-cr.opts.JanitorInterval)).
+		Find(&stale).Error; err != nil {
+		log.Printf("Janitor: failed to list tenants with a missing schema: %v", err)
+		return
+	}
+	for _, tenant := range stale {
+		log.Printf("Janitor: deleting tenant %q, whose schema is missing", tenant.SchemaName)
+		if err := cr.db.WithContext(ctx).Delete(&models.BookCounter{}, "tenant_schema = ?", tenant.SchemaName).Error; err != nil {
+			log.Printf("Janitor: failed to delete book counter of tenant %q: %v", tenant.SchemaName, err)
+		}
+		if err := cr.db.WithContext(ctx).Delete(&tenant).Error; err != nil {
+			log.Printf("Janitor: failed to delete tenant %q: %v", tenant.SchemaName, err)
+			continue
+		}
+		cr.tenants.Remove(tenant.SchemaName)
+	}
+}
@@ -0,0 +1,64 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestJanitorPass(t *testing.T) {
+	cr, e := newSQLiteServer(t, WithJanitorInterval(time.Hour))
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	// Seed drift: tenant2's row is lost, leaving its schema orphaned;
+	// tenant3's schema was dropped long ago but its row was left, and
+	// tenant4's row was just created, its schema not yet.
+	require.NoError(t, cr.db.Unscoped().Delete(&models.Tenant{}, "schema_name = ?", "tenant2").Error)
+	for schema, created := range map[string]time.Time{
+		"tenant3": time.Now().Add(-2 * time.Hour),
+		"tenant4": time.Now(),
+	} {
+		require.NoError(t, cr.db.Create(&models.Tenant{
+			Model:       gorm.Model{CreatedAt: created},
+			TenantModel: multitenancy.TenantModel{DomainURL: schema + ".example.com", SchemaName: schema},
+			Status:      models.TenantActive,
+		}).Error)
+	}
+	schemaRows := func() []string {
+		var rows []string
+		require.NoError(t, cr.db.Model(&models.Tenant{}).Order("schema_name").Pluck("schema_name", &rows).Error)
+		return rows
+	}
+
+	t.Run("Overlapping", func(t *testing.T) {
+		cr.reconciling.Lock()
+		cr.janitorPass(context.Background())
+		cr.reconciling.Unlock()
+		schemas, err := cr.tenantSchemas(context.Background())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"tenant1", "tenant2"}, schemas, "the pass is skipped")
+		assert.Equal(t, []string{"tenant1", "tenant3", "tenant4"}, schemaRows())
+	})
+
+	t.Run("Pass", func(t *testing.T) {
+		cr.janitorPass(context.Background())
+		schemas, err := cr.tenantSchemas(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tenant1"}, schemas, "the orphaned schema is dropped")
+		assert.Equal(t, []string{"tenant1", "tenant4"}, schemaRows(), "the stale row is deleted, the new one kept")
+
+		rr := serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+		assert.Equal(t, http.StatusOK, rr.Code, "healthy tenants are untouched: %s", rr.Body.String())
+		rr = serve(e, http.MethodGet, "/books", "tenant3.example.com", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+	})
+}
@@ -0,0 +1,93 @@
+package echoserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMETextEventStream is the media type of server-sent event streams.
+const MIMETextEventStream = "text/event-stream"
+
+// Job log event types.
+const (
+	jobEventStep = "step" // jobEventStep carries a [models.JobEvent].
+	jobEventDone = "done" // jobEventDone carries the final [models.JobResponse] and ends the stream.
+)
+
+// jobLogsHandler streams the steps of a background job as server-sent
+// events, ending with a done event once the job has finished. Step events
+// are numbered, so that a client reconnecting with Last-Event-ID resumes
+// after the last step it received.
+func (cr *controller) jobLogsHandler(c echo.Context) error {
+	id := c.Param("id")
+	if _, ok := cr.jobs.get(id); !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "job not found")
+	}
+	next := 0
+	if last := c.Request().Header.Get("Last-Event-ID"); last != "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Last-Event-ID must be a non-negative integer")
+		}
+		next = n + 1
+	}
+
+	ctx, end, err := cr.streams.begin(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	defer end()
+	// The stream may outlive the server's write timeout.
+	_ = http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{})
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, MIMETextEventStream)
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	for {
+		res, events, changed, ok := cr.jobs.watch(id, next)
+		if !ok {
+			return nil // the job was pruned
+		}
+		for _, ev := range events {
+			if err := writeEvent(w, strconv.Itoa(next), jobEventStep, ev); err != nil {
+				return nil // the client is gone
+			}
+			next++
+		}
+		if jobFinished(res.Status) {
+			_ = writeEvent(w, "", jobEventDone, res)
+			w.Flush()
+			return nil
+		}
+		w.Flush()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil // the client is gone or the server is shutting down
+		}
+	}
+}
+
+// writeEvent writes a server-sent event of the given type with data encoded
+// as JSON, and the given ID unless it is empty.
+func writeEvent(w io.Writer, id, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err = fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
@@ -0,0 +1,149 @@
+package echoserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedMigrator migrates tenant schemas once its gate is opened, or fails
+// with err if set.
+type gatedMigrator struct {
+	tenantMigrator
+	gate chan struct{}
+	err  error
+}
+
+func (m gatedMigrator) MigrateTenantModels(ctx context.Context, tenantID string) error {
+	select {
+	case <-m.gate:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if m.err != nil {
+		return m.err
+	}
+	return m.tenantMigrator.MigrateTenantModels(ctx, tenantID)
+}
+
+// sseEvent is a server-sent event.
+type sseEvent struct {
+	id, event, data string
+}
+
+// readEvent reads the next server-sent event from r.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return ev
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			ev.id = value
+		case "event":
+			ev.event = value
+		case "data":
+			ev.data = value
+		}
+	}
+}
+
+func TestJobLogs(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	subscribe := func(t *testing.T, m gatedMigrator) (models.JobResponse, *bufio.Reader) {
+		cr.migrations = m
+		rr := serve(e, http.MethodPost, "/tenants?async=true&seed=true", "", `{"domainUrl": "tenant1.example.com"}`)
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		job := decode[models.JobResponse](t, rr)
+		assert.Equal(t, "onboard", job.Kind)
+
+		res, err := http.Get(srv.URL + "/tenants/jobs/" + job.ID + "/logs")
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, MIMETextEventStream, res.Header.Get("Content-Type"))
+		return job, bufio.NewReader(res.Body)
+	}
+	step := func(t *testing.T, r *bufio.Reader) models.JobEvent {
+		t.Helper()
+		ev := readEvent(t, r)
+		require.Equal(t, jobEventStep, ev.event, ev.data)
+		var step models.JobEvent
+		require.NoError(t, json.Unmarshal([]byte(ev.data), &step))
+		return step
+	}
+	done := func(t *testing.T, r *bufio.Reader) models.JobResponse {
+		t.Helper()
+		ev := readEvent(t, r)
+		require.Equal(t, jobEventDone, ev.event, ev.data)
+		var res models.JobResponse
+		require.NoError(t, json.Unmarshal([]byte(ev.data), &res))
+		return res
+	}
+
+	t.Run("Failed", func(t *testing.T) {
+		gate := make(chan struct{})
+		job, r := subscribe(t, gatedMigrator{tenantMigrator: cr.db, gate: gate, err: errors.New("syntax error")})
+		assert.Equal(t, `migrating schema "tenant1"`, step(t, r).Message)
+		close(gate)
+
+		assert.Equal(t, "onboarding failed, removing the tenant", step(t, r).Message)
+		failed := step(t, r)
+		assert.Equal(t, "onboard failed", failed.Message)
+		assert.NotEmpty(t, failed.Error, "the failure is detailed")
+		res := done(t, r)
+		assert.Equal(t, job.ID, res.ID)
+		assert.Equal(t, JobFailed, res.Status)
+		_, err := r.ReadString('\n')
+		assert.Error(t, err, "the stream ends with the job")
+	})
+
+	t.Run("Succeeded", func(t *testing.T) {
+		gate := make(chan struct{})
+		job, r := subscribe(t, gatedMigrator{tenantMigrator: cr.db, gate: gate})
+		first := readEvent(t, r)
+		assert.Equal(t, "0", first.id)
+		assert.Equal(t, jobEventStep, first.event)
+		close(gate)
+
+		assert.Equal(t, "seeding 3 books", step(t, r).Message)
+		res := done(t, r)
+		assert.Equal(t, job.ID, res.ID)
+		assert.Equal(t, JobSucceeded, res.Status, res.Error)
+		assert.Equal(t, JobSucceeded, awaitJob(t, e, job.ID).Status)
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/tenants/jobs/"+job.ID+"/logs", nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", "0")
+		resumed, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resumed.Body.Close()
+		r = bufio.NewReader(resumed.Body)
+		ev := readEvent(t, r)
+		assert.Equal(t, "1", ev.id, "a reconnecting client resumes after the last event")
+		assert.Equal(t, jobEventDone, readEvent(t, r).event)
+	})
+
+	t.Run("UnknownJob", func(t *testing.T) {
+		rr := serve(e, http.MethodGet, "/tenants/jobs/unknown/logs", "", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,194 @@
+package echoserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Job statuses.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// jobRetention is how long finished jobs remain available for polling.
+const jobRetention = time.Hour
+
+type job struct {
+	models.JobResponse
+	events  []models.JobEvent
+	changed chan struct{} // changed is closed, and replaced, whenever the job changes.
+}
+
+// jobLogKey is the context key of the function logging the steps of the job
+// running under the context.
+type jobLogKey struct{}
+
+// logJobStep records a step of the job running under ctx, if any, for the
+// job logs endpoint.
+func logJobStep(ctx context.Context, format string, args ...any) {
+	if logStep, ok := ctx.Value(jobLogKey{}).(func(string)); ok {
+		logStep(fmt.Sprintf(format, args...))
+	}
+}
+
+func (j *job) log(message, errMessage string) {
+	j.events = append(j.events, models.JobEvent{
+		Time:    time.Now().UTC(),
+		Message: message,
+		Error:   errMessage,
+	})
+}
+
+// jobFinished reports whether a job with the given status has succeeded or
+// failed.
+func jobFinished(status string) bool {
+	return status == JobSucceeded || status == JobFailed
+}
+
+// jobStore runs background jobs and keeps their status for polling. Jobs run
+// under the store's context, which is canceled on shutdown.
+type jobStore struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newJobStore() *jobStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobStore{jobs: make(map[string]*job), ctx: ctx, cancel: cancel}
+}
+
+// runJob runs fn for the job res, turning a panic into the job's error so
+// that a failing job cannot take the server down. The panic and its stack
+// are logged; like any other error, the job only reports it as internal.
+func runJob(ctx context.Context, res models.JobResponse, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s (%s of tenant %q) panicked: %v\n%s", res.ID, res.Kind, res.Tenant, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// start runs fn in the background and returns the job's initial status.
+func (s *jobStore) start(kind, tenant string, fn func(ctx context.Context) error) models.JobResponse {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	j := &job{
+		JobResponse: models.JobResponse{
+			ID:        hex.EncodeToString(id),
+			Kind:      kind,
+			Tenant:    tenant,
+			Status:    JobPending,
+			CreatedAt: time.Now().UTC(),
+		},
+		changed: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.prune()
+	s.jobs[j.ID] = j
+	res := j.JobResponse
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.update(j, func(j *job) { j.Status = JobRunning })
+		ctx := context.WithValue(s.ctx, jobLogKey{}, func(message string) {
+			s.update(j, func(j *job) { j.log(message, "") })
+		})
+		err := runJob(ctx, j.JobResponse, fn)
+		s.update(j, func(j *job) {
+			now := time.Now().UTC()
+			j.FinishedAt = &now
+			if err != nil {
+				log.Printf("Job %s (%s of tenant %q) failed: %v", j.ID, j.Kind, j.Tenant, err)
+				j.Status = JobFailed
+				j.Error = categoryInfo[classifyError(err)].message
+				j.log(j.Kind+" failed", j.Error)
+				return
+			}
+			j.Status = JobSucceeded
+		})
+	}()
+	return res
+}
+
+// update changes j, waking up the watchers of the job.
+func (s *jobStore) update(j *job, fn func(*job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(j)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// get returns the status of the job with the given ID.
+func (s *jobStore) get(id string) (models.JobResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return models.JobResponse{}, false
+	}
+	return j.JobResponse, true
+}
+
+// watch returns the status of the job with the given ID and its events from
+// the from'th on, along with a channel that is closed when the job changes.
+func (s *jobStore) watch(id string, from int) (models.JobResponse, []models.JobEvent, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return models.JobResponse{}, nil, nil, false
+	}
+	var events []models.JobEvent
+	if from < len(j.events) {
+		events = slices.Clone(j.events[from:])
+	}
+	return j.JobResponse, events, j.changed, true
+}
+
+// prune forgets jobs that finished more than [jobRetention] ago. The caller
+// must hold s.mu.
+func (s *jobStore) prune() {
+	for id, j := range s.jobs {
+		if j.FinishedAt != nil && time.Since(*j.FinishedAt) > jobRetention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// shutdown cancels the running jobs and waits for them to return, or for ctx
+// to be done.
+func (s *jobStore) shutdown(ctx context.Context) error {
+	s.cancel()
+	return wait(ctx, &s.wg)
+}
+
+func (cr *controller) getJobHandler(c echo.Context) error {
+	res, ok := cr.jobs.get(c.Param("id"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "job not found")
+	}
+	return respond(c, http.StatusOK, res)
+}
@@ -0,0 +1,114 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// failingOffboarder fails every offboard.
+type failingOffboarder struct {
+	tenantMigrator
+}
+
+func (failingOffboarder) OffboardTenant(context.Context, string) error {
+	return errors.New("drop schema: lock timeout")
+}
+
+// awaitJob polls the job until it finishes.
+func awaitJob(t *testing.T, e *echo.Echo, id string) models.JobResponse {
+	t.Helper()
+	var res models.JobResponse
+	require.Eventually(t, func() bool {
+		rr := serve(e, http.MethodGet, "/tenants/jobs/"+id, "", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		res = decode[models.JobResponse](t, rr)
+		return res.Status == JobSucceeded || res.Status == JobFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	return res
+}
+
+func TestAsyncOffboard(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = serve(e, http.MethodDelete, "/tenants/1?async=true&confirm=true", "", "")
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	job := decode[models.JobResponse](t, rr)
+	assert.Equal(t, "/tenants/jobs/"+job.ID, rr.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, "offboard", job.Kind)
+	assert.Equal(t, "tenant1", job.Tenant)
+
+	job = awaitJob(t, e, job.ID)
+	assert.Equal(t, JobSucceeded, job.Status, job.Error)
+	assert.NotNil(t, job.FinishedAt)
+
+	schemas, err := cr.tenantSchemas(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, schemas, "tenant1", "tenant schema is dropped")
+	assert.ErrorIs(t, cr.db.First(&models.Tenant{}, 1).Error, gorm.ErrRecordNotFound, "tenant row is deleted")
+}
+
+func TestAsyncOffboardFailure(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	cr.migrations = failingOffboarder{cr.db}
+
+	rr = serve(e, http.MethodDelete, "/tenants/1?async=true&confirm=true", "", "")
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	job := awaitJob(t, e, decode[models.JobResponse](t, rr).ID)
+	assert.Equal(t, JobFailed, job.Status)
+	assert.NotEmpty(t, job.Error)
+
+	assert.NoError(t, cr.db.First(&models.Tenant{}, 1).Error, "tenant row is kept")
+}
+
+func TestAsyncOffboardRequests(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = serve(e, http.MethodDelete, "/tenants/1?async=maybe", "", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(e, http.MethodGet, "/tenants/jobs/unknown", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestJobStoreShutdown(t *testing.T) {
+	s := newJobStore()
+	res := s.start("offboard", "tenant1", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.shutdown(ctx), "running jobs are canceled and awaited")
+	job, ok := s.get(res.ID)
+	require.True(t, ok)
+	assert.Equal(t, JobFailed, job.Status)
+}
+
+func TestJobPanic(t *testing.T) {
+	s := newJobStore()
+	res := s.start("offboard", "tenant1", func(context.Context) error {
+		panic("database password is hunter2")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.shutdown(ctx), "the panic does not take the server down")
+	job, ok := s.get(res.ID)
+	require.True(t, ok)
+	assert.Equal(t, JobFailed, job.Status)
+	assert.Equal(t, "internal server error", job.Error, "the panic is not leaked")
+	assert.NotNil(t, job.FinishedAt)
+}
@@ -0,0 +1,60 @@
+package echoserver
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	echomw "github.com/bartventer/gorm-multitenancy/middleware/echo/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// tenantClaims are the claims of a bearer token identifying the tenant of a
+// request.
+type tenantClaims struct {
+	Tenant string `json:"tenant"`
+	jwt.RegisteredClaims
+}
+
+// withJWTTenant returns a middleware that resolves the tenant from the
+// "tenant" claim of the request's bearer token and stores it under
+// [echomw.TenantKey], so handlers are unaware of the resolution strategy.
+func (cr *controller) withJWTTenant() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cr.skipTenant(c.Request().URL.Path) {
+				return next(c)
+			}
+			raw, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || raw == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing bearer token")
+			}
+			claims := &tenantClaims{}
+			if _, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (any, error) {
+				// An empty key would verify tokens anyone can sign.
+				if len(cr.opts.JWTKey) == 0 {
+					return nil, errors.New("no JWT key configured")
+				}
+				return cr.opts.JWTKey, nil
+			}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}), jwt.WithExpirationRequired()); err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid bearer token")
+			}
+			if claims.Tenant == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "missing tenant claim")
+			}
+			status, err := cr.resolveTenant(c.Request().Context(), claims.Tenant)
+			if err != nil {
+				return err
+			}
+			if status == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "unknown tenant")
+			}
+			if err = inactiveTenantError(status); err != nil {
+				return err
+			}
+			c.Set(echomw.TenantKey.String(), claims.Tenant)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package echoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signTenantToken(t *testing.T, key []byte, tenant string) string {
+	t.Helper()
+	return signClaims(t, key, tenantClaims{
+		Tenant:           tenant,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	})
+}
+
+func signClaims(t *testing.T, key []byte, claims tenantClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWTTenant(t *testing.T) {
+	key := []byte("jwt-secret")
+	cr := &controller{
+		opts:    Options{TenantStrategy: TenantFromJWT, JWTKey: key},
+		tenants: staticTenants("tenant1"),
+	}
+	e := echo.New()
+	cr.init(e)
+	e.GET("/tenants/ping", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/whoami", func(c echo.Context) error {
+		tenantID, err := TenantFromContext(c)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, tenantID)
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		auth   string
+		status int
+	}{
+		{"MissingToken", "/books", "", http.StatusUnauthorized},
+		{"NotBearer", "/books", "Basic Zm9vOmJhcg==", http.StatusUnauthorized},
+		{"InvalidSignature", "/books", "Bearer " + signTenantToken(t, []byte("other"), "tenant1"), http.StatusUnauthorized},
+		{"MissingTenantClaim", "/books", "Bearer " + signTenantToken(t, key, ""), http.StatusUnauthorized},
+		{"MissingExpiry", "/whoami", "Bearer " + signClaims(t, key, tenantClaims{Tenant: "tenant1"}), http.StatusUnauthorized},
+		{"Expired", "/whoami", "Bearer " + signClaims(t, key, tenantClaims{
+			Tenant:           "tenant1",
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))},
+		}), http.StatusUnauthorized},
+		{"UnknownTenant", "/whoami", "Bearer " + signTenantToken(t, key, "tenant9"), http.StatusUnauthorized},
+		{"ValidToken", "/whoami", "Bearer " + signTenantToken(t, key, "tenant1"), http.StatusOK},
+		{"SkipsTenantRoutes", "/tenants/ping", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.auth)
+			}
+			rr := httptest.NewRecorder()
+			e.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+}
+
+func TestJWTTenantWithoutKey(t *testing.T) {
+	cr := &controller{
+		opts:    Options{TenantStrategy: TenantFromJWT},
+		tenants: staticTenants("tenant1"),
+	}
+	e := echo.New()
+	cr.init(e)
+	e.GET("/whoami", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+signTenantToken(t, []byte{}, "tenant1"))
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "a token signed with an empty key must not be trusted")
+}
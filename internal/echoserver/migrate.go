@@ -0,0 +1,167 @@
+package echoserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultMigrationConcurrency = 4
+
+// MIMEApplicationNDJSON is the media type of newline-delimited JSON streams.
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+// migrateTenantHandler brings the schema of an existing tenant up to date
+// with the registered models. Migrations are idempotent, so it is safe to
+// call repeatedly. The first migration of a pending tenant activates it.
+func (cr *controller) migrateTenantHandler(c echo.Context) error {
+	id, err := tenantIDParam(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	tenant := &models.Tenant{}
+	if err := cr.db.WithContext(ctx).First(tenant, id).Error; err != nil {
+		return err
+	}
+	if err := cr.migrateTenant(ctx, tenant.SchemaName); err != nil {
+		return err
+	}
+	if tenant.Status == models.TenantPending {
+		// The first migration completes the onboarding of a tenant created
+		// without one.
+		if err := cr.db.WithContext(ctx).Model(tenant).Update("status", models.TenantActive).Error; err != nil {
+			return err
+		}
+		cr.tenants.Add(tenant.SchemaName)
+	}
+	return respond(c, http.StatusOK, tenantResponse(tenant))
+}
+
+// migrateTenantsHandler migrates the schema of every tenant, at most
+// [Options.MigrationConcurrency] at a time. A failing tenant does not stop
+// the others; failures are reported per tenant and turn the status into 500.
+func (cr *controller) migrateTenantsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	var tenants []models.Tenant
+	if err := cr.db.WithContext(ctx).Scopes(onboardedTenants).Find(&tenants).Error; err != nil {
+		return err
+	}
+	res := models.MigrateTenantsResponse{Migrated: []string{}}
+	cr.migrateTenants(ctx, tenants, func(schema string, err error) {
+		if err != nil {
+			if res.Failed == nil {
+				res.Failed = make(map[string]string)
+			}
+			res.Failed[schema] = categoryInfo[classifyError(err)].message
+			return
+		}
+		res.Migrated = append(res.Migrated, schema)
+	})
+
+	slices.Sort(res.Migrated)
+	status := http.StatusOK
+	if len(res.Failed) > 0 {
+		status = http.StatusInternalServerError
+	}
+	return respond(c, status, res)
+}
+
+// migrateTenantsStreamHandler migrates every tenant like
+// [controller.migrateTenantsHandler], but streams a
+// [models.MigrationProgress] record per tenant as newline-delimited JSON as
+// soon as its migration completes. The status is always 200, since it is
+// sent before the outcome is known; failures are reported in the records.
+// If the client goes away, no further migrations are started.
+func (cr *controller) migrateTenantsStreamHandler(c echo.Context) error {
+	ctx, end, err := cr.streams.begin(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	defer end()
+	var tenants []models.Tenant
+	if err := cr.db.WithContext(ctx).Scopes(onboardedTenants).Find(&tenants).Error; err != nil {
+		return err
+	}
+
+	// The stream may outlive the server's write timeout.
+	_ = http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{})
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	enc := json.NewEncoder(w)
+	done := 0
+	cr.migrateTenants(ctx, tenants, func(schema string, err error) {
+		done++
+		p := models.MigrationProgress{
+			Tenant: schema,
+			Status: "migrated",
+			Done:   done,
+			Total:  len(tenants),
+		}
+		if err != nil {
+			p.Status = "failed"
+			p.Error = categoryInfo[classifyError(err)].message
+		}
+		if ctx.Err() != nil {
+			return // the client is gone or the server is shutting down
+		}
+		if err := enc.Encode(p); err != nil {
+			log.Printf("Failed to stream migration progress: %v", err)
+			return
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// migrateTenants migrates the schemas of tenants, at most
+// [Options.MigrationConcurrency] at a time, calling report with the outcome
+// of each as it completes. Calls to report are serialized. Once ctx is done
+// no further migrations are started.
+func (cr *controller) migrateTenants(ctx context.Context, tenants []models.Tenant, report func(schema string, err error)) {
+	limit := cr.opts.MigrationConcurrency
+	if limit <= 0 {
+		limit = defaultMigrationConcurrency
+	}
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, limit)
+	)
+	for _, tenant := range tenants {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := cr.migrateTenant(ctx, tenant.SchemaName)
+			if err != nil {
+				log.Printf("Failed to migrate tenant %q: %v", tenant.SchemaName, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report(tenant.SchemaName, err)
+		}()
+	}
+	wg.Wait()
+}
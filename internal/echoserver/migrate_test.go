@@ -0,0 +1,175 @@
+package echoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingMigrator tracks how many migrations run at once and fails the
+// migration of the tenant named fail.
+type countingMigrator struct {
+	tenantMigrator
+	fail              string
+	inFlight, maxSeen atomic.Int32
+}
+
+func (m *countingMigrator) MigrateTenantModels(ctx context.Context, tenantID string) error {
+	n := m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
+	for {
+		seen := m.maxSeen.Load()
+		if n <= seen || m.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if tenantID == m.fail {
+		return errors.New("migration failed")
+	}
+	return m.tenantMigrator.MigrateTenantModels(ctx, tenantID)
+}
+
+func serveAdmin(e *echo.Echo, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMigrateTenant(t *testing.T) {
+	const token = "admin-secret"
+	_, e := newSQLiteServer(t, WithAdminToken(token))
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	for i := range 2 {
+		rr = serveAdmin(e, http.MethodPost, "/tenants/1/migrate", token)
+		require.Equal(t, http.StatusOK, rr.Code, "migration %d: %s", i, rr.Body.String())
+		assert.JSONEq(t, `{"id": 1, "domainUrl": "tenant1.example.com", "status": "active"}`, rr.Body.String())
+	}
+	rr = serve(e, http.MethodPost, "/books", "tenant1.example.com", `{"name": "Book 1", "author": "Author 1"}`)
+	assert.Equal(t, http.StatusCreated, rr.Code, "schema is usable after migrating: %s", rr.Body.String())
+
+	rr = serveAdmin(e, http.MethodPost, "/tenants/9/migrate", token)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	for _, id := range []string{"abc", "0", "1%20OR%201=1"} {
+		rr = serveAdmin(e, http.MethodPost, "/tenants/"+id+"/migrate", token)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, id)
+	}
+	rr = serveAdmin(e, http.MethodPost, "/tenants/1/migrate", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMigrateTenants(t *testing.T) {
+	const token = "admin-secret"
+	cr, e := newSQLiteServer(t, WithAdminToken(token), WithMigrationConcurrency(2))
+	for i := range 5 {
+		rr := serve(e, http.MethodPost, "/tenants", "", fmt.Sprintf(`{"domainUrl": "tenant%d.example.com"}`, i+1))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	m := &countingMigrator{tenantMigrator: cr.db, fail: "tenant3"}
+	cr.migrations = m
+
+	rr := serveAdmin(e, http.MethodPost, "/tenants/migrate", token)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	res := decode[models.MigrateTenantsResponse](t, rr)
+	assert.Equal(t, []string{"tenant1", "tenant2", "tenant4", "tenant5"}, res.Migrated, "one failure does not abort the rest")
+	assert.Equal(t, map[string]string{"tenant3": categoryInfo[CategoryInternal].message}, res.Failed)
+	assert.LessOrEqual(t, m.maxSeen.Load(), int32(2), "concurrency limit is respected")
+
+	m.fail = ""
+	rr = serveAdmin(e, http.MethodPost, "/tenants/migrate", token)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Len(t, decode[models.MigrateTenantsResponse](t, rr).Migrated, 5)
+}
+
+// serveStream sends an admin request to e with ctx, returning the decoded
+// NDJSON records.
+func serveStream(t *testing.T, ctx context.Context, e *echo.Echo, path, token string) (*httptest.ResponseRecorder, []models.MigrationProgress) {
+	t.Helper()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, path, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	var records []models.MigrationProgress
+	dec := json.NewDecoder(rr.Body)
+	for dec.More() {
+		var p models.MigrationProgress
+		require.NoError(t, dec.Decode(&p))
+		records = append(records, p)
+	}
+	return rr, records
+}
+
+func TestMigrateTenantsStream(t *testing.T) {
+	const token = "admin-secret"
+	cr, e := newSQLiteServer(t, WithAdminToken(token), WithMigrationConcurrency(2), WithCompression(1))
+	for i := range 5 {
+		rr := serve(e, http.MethodPost, "/tenants", "", fmt.Sprintf(`{"domainUrl": "tenant%d.example.com"}`, i+1))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	cr.migrations = &countingMigrator{tenantMigrator: cr.db, fail: "tenant3"}
+
+	rr, records := serveStream(t, context.Background(), e, "/tenants/migrate/stream", token)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, MIMEApplicationNDJSON, rr.Header().Get(echo.HeaderContentType))
+	assert.Empty(t, rr.Header().Get(echo.HeaderContentEncoding), "streams are not compressed")
+	require.Len(t, records, 5, "one record per tenant")
+	var migrated []string
+	for i, p := range records {
+		assert.Equal(t, i+1, p.Done)
+		assert.Equal(t, 5, p.Total)
+		if p.Tenant == "tenant3" {
+			assert.Equal(t, "failed", p.Status)
+			assert.Equal(t, categoryInfo[CategoryInternal].message, p.Error)
+			continue
+		}
+		assert.Equal(t, "migrated", p.Status, p.Tenant)
+		migrated = append(migrated, p.Tenant)
+	}
+	assert.ElementsMatch(t, []string{"tenant1", "tenant2", "tenant4", "tenant5"}, migrated, "one failure does not abort the rest")
+}
+
+// cancelingMigrator cancels the request after the first migration.
+type cancelingMigrator struct {
+	tenantMigrator
+	cancel context.CancelFunc
+	calls  atomic.Int32
+}
+
+func (m *cancelingMigrator) MigrateTenantModels(ctx context.Context, tenantID string) error {
+	m.calls.Add(1)
+	m.cancel()
+	return m.tenantMigrator.MigrateTenantModels(ctx, tenantID)
+}
+
+func TestMigrateTenantsStreamCanceled(t *testing.T) {
+	const token = "admin-secret"
+	cr, e := newSQLiteServer(t, WithAdminToken(token), WithMigrationConcurrency(1))
+	for i := range 5 {
+		rr := serve(e, http.MethodPost, "/tenants", "", fmt.Sprintf(`{"domainUrl": "tenant%d.example.com"}`, i+1))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := &cancelingMigrator{tenantMigrator: cr.db, cancel: cancel}
+	cr.migrations = m
+
+	_, records := serveStream(t, ctx, e, "/tenants/migrate/stream", token)
+	assert.Empty(t, records, "nothing is written to a client that is gone")
+	assert.Equal(t, int32(1), m.calls.Load(), "no migrations start after cancellation")
+}
@@ -0,0 +1,57 @@
+package echoserver
+
+import (
+	"context"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+)
+
+// offboardPreview describes what offboarding the tenant would drop: the
+// tables of its schema and their number of rows, soft-deleted rows included
+// since they are dropped too. Nothing is changed.
+func (cr *controller) offboardPreview(ctx context.Context, tenant *models.Tenant) (*models.OffboardPreviewResponse, error) {
+	tables, err := cr.schemaTables(ctx, tenant.SchemaName)
+	if err != nil {
+		return nil, err
+	}
+	preview := &models.OffboardPreviewResponse{
+		Schema: tenant.SchemaName,
+		Tables: tables,
+		Rows:   make(map[string]int, len(tables)),
+	}
+	if err = cr.withTenantTx(ctx, tenant.SchemaName, func(tx *multitenancy.DB) error {
+		for _, table := range tables {
+			var n int64
+			if err := tx.Table(table).Count(&n).Error; err != nil {
+				return err
+			}
+			preview.Rows[table] = int(n)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// schemaTables lists the tables the schema actually holds, by name, rather
+// than those of the registered models: dropping the schema drops them all.
+func (cr *controller) schemaTables(ctx context.Context, schema string) ([]string, error) {
+	db := cr.db.WithContext(ctx)
+	tables := []string{}
+	switch db.Dialector.Name() {
+	case "sqlite":
+		// Each schema is an attached database, see initdb.
+		if err := db.Raw(`SELECT name FROM "` + schema + `".sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`).
+			Scan(&tables).Error; err != nil {
+			return nil, err
+		}
+	default:
+		if err := db.Raw("SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_type = 'BASE TABLE' ORDER BY table_name",
+			schema).Scan(&tables).Error; err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
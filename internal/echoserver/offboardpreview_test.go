@@ -0,0 +1,49 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffboardPreview(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	for _, book := range []string{`{"name": "Dune", "author": "Frank Herbert"}`, `{"name": "Emma", "author": "Jane Austen"}`} {
+		rr = serve(e, http.MethodPost, "/books", host, book)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	rr = serve(e, http.MethodDelete, "/books/2", host, "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	// A table no model knows of is dropped with the schema all the same.
+	require.NoError(t, cr.db.Exec(`CREATE TABLE "tenant1".notes (id INTEGER PRIMARY KEY)`).Error)
+	require.NoError(t, cr.db.Exec(`INSERT INTO "tenant1".notes (id) VALUES (1)`).Error)
+
+	for _, path := range []string{"/tenants/1", "/tenants/1?confirm=false", "/tenants/1?async=true"} {
+		rr = serve(e, http.MethodDelete, path, "", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, models.OffboardPreviewResponse{
+			Schema: "tenant1",
+			Tables: []string{models.TableNameBook, "notes", models.TableNameTag},
+			Rows:   map[string]int{models.TableNameBook: 2, "notes": 1, models.TableNameTag: 0},
+		}, decode[models.OffboardPreviewResponse](t, rr), "%s is a dry-run, counting deleted books", path)
+	}
+	reset, err := cr.db.UseTenant(context.Background(), "tenant1")
+	require.NoError(t, err, "the schema is intact")
+	require.NoError(t, reset())
+	rr = serve(e, http.MethodGet, "/books/1", host, "")
+	assert.Equal(t, http.StatusOK, rr.Code, "the books are still served: %s", rr.Body.String())
+
+	rr = serve(e, http.MethodDelete, "/tenants/1?confirm=maybe", "", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serve(e, http.MethodDelete, "/tenants/1?confirm=true", "", "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	_, err = cr.db.UseTenant(context.Background(), "tenant1")
+	assert.Error(t, err, "a confirmed delete drops the schema")
+}
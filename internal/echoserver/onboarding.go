@@ -0,0 +1,91 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/labstack/echo/v4"
+)
+
+// tenantMigrator creates and drops tenant schemas. It is satisfied by
+// *multitenancy.DB.
+type tenantMigrator interface {
+	MigrateTenantModels(ctx context.Context, tenantID string) error
+	OffboardTenant(ctx context.Context, tenantID string) error
+}
+
+// migrator returns the migrator of the tenant's schema, defaulting to the
+// database holding it. Schema changes are one-off, so their statements are
+// not prepared.
+func (cr *controller) migrator(ctx context.Context, tenantID string) (tenantMigrator, error) {
+	if cr.migrations != nil {
+		return cr.migrations, nil
+	}
+	return cr.schemaDB(ctx, tenantID)
+}
+
+// migrateTenant brings the tenant's schema up to date with the models.
+func (cr *controller) migrateTenant(ctx context.Context, tenantID string) (err error) {
+	ctx, span := cr.startSpan(ctx, "migrate tenant", tenantAttribute.String(tenantID))
+	defer func() { endSpan(span, err) }()
+	m, err := cr.migrator(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if err = m.MigrateTenantModels(ctx, tenantID); err != nil {
+		return err
+	}
+	// Statements prepared before the migration may depend on the old tables.
+	cr.resetStatements(tenantID)
+	return nil
+}
+
+// resetStatements closes the statements prepared for the tenant's schema, if
+// any.
+func (cr *controller) resetStatements(tenantID string) {
+	if cr.stmts != nil {
+		cr.stmts.reset(tenantID)
+	}
+}
+
+// onboardTenant migrates the schema of a newly created tenant, and seeds it
+// with the given books if any, within the onboarding budget. If onboarding
+// fails or runs out of time, the tenant row and any partially created schema
+// are removed, so the tenant can be created again.
+func (cr *controller) onboardTenant(ctx context.Context, tenant *models.Tenant, seed []models.Book) error {
+	if timeout := cr.opts.OnboardingTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	logJobStep(ctx, "migrating schema %q", tenant.SchemaName)
+	err := cr.migrateTenantWithRetry(ctx, tenant.SchemaName)
+	if err == nil && len(seed) > 0 {
+		logJobStep(ctx, "seeding %d books", len(seed))
+		var db *multitenancy.DB
+		if db, err = cr.tenantDB(ctx, tenant.SchemaName); err == nil {
+			err = SeedTenant(ctx, db, tenant.SchemaName, seed)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	logJobStep(ctx, "onboarding failed, removing the tenant")
+	// The request context may be done, so clean up regardless.
+	cleanupCtx := context.WithoutCancel(ctx)
+	if dropErr := cr.offboardTenant(cleanupCtx, tenant.SchemaName); dropErr != nil {
+		log.Printf("Failed to drop schema of tenant %q after failed onboarding: %v", tenant.SchemaName, dropErr)
+	}
+	if delErr := cr.db.WithContext(cleanupCtx).Unscoped().Delete(tenant).Error; delErr != nil {
+		log.Printf("Failed to delete tenant %q after failed onboarding: %v", tenant.SchemaName, delErr)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return echo.NewHTTPError(http.StatusGatewayTimeout, "tenant onboarding timed out")
+	}
+	return err
+}
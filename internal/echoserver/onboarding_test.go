@@ -0,0 +1,84 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowMigrator creates the tenant schema, then stalls until ctx is done,
+// like a migration stuck on a lock.
+type slowMigrator struct {
+	tenantMigrator
+}
+
+func (m slowMigrator) MigrateTenantModels(ctx context.Context, tenantID string) error {
+	if err := m.tenantMigrator.MigrateTenantModels(ctx, tenantID); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestOnboardingTimeout(t *testing.T) {
+	cr, e := newSQLiteServer(t, WithOnboardingTimeout(50*time.Millisecond))
+	cr.migrations = slowMigrator{cr.db}
+	const host = "tenant1.example.com"
+
+	start := time.Now()
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code, rr.Body.String())
+	assert.Less(t, time.Since(start), 5*time.Second, "onboarding is aborted")
+
+	rr = serve(e, http.MethodGet, "/tenants/1", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "tenant row is removed")
+	_, err := cr.db.UseTenant(context.Background(), "tenant1")
+	assert.Error(t, err, "tenant schema is dropped")
+	rr = serve(e, http.MethodGet, "/books", host, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "tenant is not served")
+
+	// A real migration may take longer than the budget on a busy machine.
+	cr.migrations, cr.opts.OnboardingTimeout = nil, 0
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "tenant can be onboarded again: %s", rr.Body.String())
+	rr = serve(e, http.MethodGet, "/books", host, "")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+// tallyMigrator counts the tenant schemas migrated.
+type tallyMigrator struct {
+	tenantMigrator
+	migrated *atomic.Int32
+}
+
+func (m tallyMigrator) MigrateTenantModels(ctx context.Context, tenantID string) error {
+	m.migrated.Add(1)
+	return m.tenantMigrator.MigrateTenantModels(ctx, tenantID)
+}
+
+func TestDuplicateTenant(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	var migrated atomic.Int32
+	cr.migrations = tallyMigrator{cr.db, &migrated}
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// The same domain, and another one claiming the same schema.
+	for _, domain := range []string{"tenant1.example.com", "tenant1.example.org"} {
+		rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+domain+`"}`)
+		require.Equal(t, http.StatusConflict, rr.Code, "%s: %s", domain, rr.Body.String())
+		assert.Equal(t, `tenant "tenant1" already exists`, decode[models.ErrorResponse](t, rr).Message, domain)
+	}
+	assert.EqualValues(t, 1, migrated.Load(), "no schema is migrated for a duplicate")
+	var n int64
+	require.NoError(t, cr.db.Model(&models.Tenant{}).Count(&n).Error)
+	assert.EqualValues(t, 1, n)
+	rr = serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "the original tenant is untouched: %s", rr.Body.String())
+}
@@ -0,0 +1,183 @@
+package echoserver
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/labstack/echo/v4"
+)
+
+// tenantHeader is the request header the tenant middleware falls back to when
+// the tenant cannot be resolved from the subdomain.
+const tenantHeader = "X-Tenant"
+
+// openAPI builds the OpenAPI 3 document describing the route table, so the
+// spec cannot drift from the registered routes.
+func (c *controller) openAPI() (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "gorm-multitenancy example API",
+			Version: "1.0.0",
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: make(openapi3.Schemas),
+			Parameters: openapi3.ParametersMap{
+				"Tenant": {Value: openapi3.NewHeaderParameter(tenantHeader).
+					WithDescription("Tenant schema name, used when the tenant is not resolved from the subdomain.").
+					WithSchema(openapi3.NewStringSchema())},
+				"IdempotencyKey": {Value: openapi3.NewHeaderParameter(HeaderIdempotencyKey).
+					WithDescription("Key identifying the request, so that retrying it replays the original response.").
+					WithSchema(openapi3.NewStringSchema())},
+			},
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"admin": {Value: openapi3.NewSecurityScheme().WithType("http").WithScheme("bearer").
+					WithDescription("Admin token configured on the server.")},
+				"tenant": {Value: openapi3.NewJWTSecurityScheme().
+					WithDescription(`Bearer token carrying a "tenant" claim.`)},
+			},
+		},
+	}
+
+	if base := c.basePath(); base != "" {
+		doc.Servers = openapi3.Servers{{URL: base}}
+	}
+
+	for _, r := range c.routes() {
+		op := openapi3.NewOperation()
+		op.Summary = r.summary
+		for _, seg := range strings.Split(r.path, "/") {
+			if name, ok := strings.CutPrefix(seg, ":"); ok {
+				op.AddParameter(openapi3.NewPathParameter(name).WithSchema(openapi3.NewStringSchema()))
+			}
+		}
+		if r.tenant {
+			if c.opts.TenantStrategy == TenantFromJWT {
+				op.Security = openapi3.NewSecurityRequirements().With(openapi3.NewSecurityRequirement().Authenticate("tenant"))
+			} else {
+				op.Parameters = append(op.Parameters, &openapi3.ParameterRef{Ref: "#/components/parameters/Tenant"})
+			}
+		}
+		if r.idempotent {
+			op.Parameters = append(op.Parameters, &openapi3.ParameterRef{Ref: "#/components/parameters/IdempotencyKey"})
+		}
+		if r.admin {
+			op.Security = openapi3.NewSecurityRequirements().With(openapi3.NewSecurityRequirement().Authenticate("admin"))
+		}
+		if r.request != nil {
+			schema, err := schemaRef(doc.Components.Schemas, r.request)
+			if err != nil {
+				return nil, err
+			}
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+				WithRequired(true).
+				WithJSONSchemaRef(schema)}
+		}
+		res := openapi3.NewResponse().WithDescription(http.StatusText(r.status))
+		switch {
+		case r.stream:
+			// Streams are a sequence of the documented records, in the
+			// route's own media type, or plain text such as CSV.
+			schema := openapi3.NewStringSchema().NewRef()
+			if r.response != nil {
+				var err error
+				if schema, err = schemaRef(doc.Components.Schemas, r.response); err != nil {
+					return nil, err
+				}
+			}
+			res = res.WithContent(openapi3.NewContentWithSchemaRef(schema, []string{r.mediaType}))
+		case r.response != nil:
+			schema, err := schemaRef(doc.Components.Schemas, r.response)
+			if err != nil {
+				return nil, err
+			}
+			res = res.WithJSONSchemaRef(schema)
+		}
+		op.Responses = openapi3.NewResponses(
+			openapi3.WithStatus(r.status, &openapi3.ResponseRef{Value: res}),
+			openapi3.WithName("default", openapi3.NewResponse().WithDescription("Error")),
+		)
+		doc.AddOperation(openAPIPath(r.path), r.method, op)
+	}
+	return doc, nil
+}
+
+// openAPIPath converts an echo route path to an OpenAPI path template.
+func openAPIPath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if name, ok := strings.CutPrefix(seg, ":"); ok {
+			segs[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// schemaRef returns a schema for the type of v. Named struct types are added
+// to schemas and referenced; slices and maps of them are described inline.
+func schemaRef(schemas openapi3.Schemas, v any) (*openapi3.SchemaRef, error) {
+	t := reflect.TypeOf(v)
+	switch t.Kind() {
+	case reflect.Slice:
+		items, err := schemaRef(schemas, reflect.Zero(t.Elem()).Interface())
+		if err != nil {
+			return nil, err
+		}
+		s := openapi3.NewArraySchema()
+		s.Items = items
+		return s.NewRef(), nil
+	case reflect.Map:
+		values, err := schemaRef(schemas, reflect.Zero(t.Elem()).Interface())
+		if err != nil {
+			return nil, err
+		}
+		s := openapi3.NewObjectSchema()
+		s.AdditionalProperties = openapi3.AdditionalProperties{Schema: values}
+		return s.NewRef(), nil
+	}
+	name := t.Name()
+	if name == "" || t.Kind() != reflect.Struct {
+		return openapi3gen.NewSchemaRefForValue(v, nil)
+	}
+	if _, ok := schemas[name]; !ok {
+		ref, err := openapi3gen.NewSchemaRefForValue(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		schemas[name] = ref
+	}
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, nil), nil
+}
+
+func (c *controller) openAPIHandler(ctx echo.Context) error {
+	doc, err := c.openAPI()
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, doc)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8" />
+    <title>API Docs</title>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+  </head>
+  <body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script>
+      window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+    </script>
+  </body>
+</html>
+`
+
+func swaggerUIHandler(c echo.Context) error {
+	return c.HTML(http.StatusOK, swaggerUIPage)
+}
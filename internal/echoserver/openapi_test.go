@@ -0,0 +1,64 @@
+package echoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	for _, strategy := range []TenantStrategy{TenantFromHost, TenantFromJWT} {
+		cr := &controller{opts: Options{TenantStrategy: strategy}}
+		e := echo.New()
+		cr.init(e)
+
+		req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		loader := openapi3.NewLoader()
+		doc, err := loader.LoadFromData(rr.Body.Bytes())
+		require.NoError(t, err)
+		require.NoError(t, doc.Validate(loader.Context))
+
+		for _, r := range cr.routes() {
+			item := doc.Paths.Find(openAPIPath(r.path))
+			if assert.NotNil(t, item, "missing path %s", r.path) {
+				assert.NotNil(t, item.GetOperation(r.method), "missing operation %s %s", r.method, r.path)
+			}
+		}
+		for path, mediaType := range map[string]string{
+			"/tenants/jobs/{id}/logs": MIMETextEventStream,
+			"/tenants/migrate/stream": MIMEApplicationNDJSON,
+			"/books/export":           MIMETextCSV,
+		} {
+			op := doc.Paths.Find(path).Get
+			if op == nil {
+				op = doc.Paths.Find(path).Post
+			}
+			content := op.Responses.Status(http.StatusOK).Value.Content
+			assert.Len(t, content, 1, path)
+			assert.Contains(t, content, mediaType, path)
+		}
+		assert.Contains(t, doc.Components.Schemas, "BookResponse")
+		assert.Contains(t, doc.Components.Schemas, "CreateTenantBody")
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	cr := &controller{}
+	e := echo.New()
+	cr.init(e)
+
+	req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "SwaggerUIBundle")
+}
@@ -0,0 +1,615 @@
+package echoserver
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// TenantStrategy selects how the tenant of a request is resolved.
+type TenantStrategy int
+
+const (
+	// TenantFromHost resolves the tenant from the request subdomain or header
+	// using the gorm-multitenancy WithTenant middleware. This is the default.
+	TenantFromHost TenantStrategy = iota
+	// TenantFromJWT resolves the tenant from the "tenant" claim of a bearer
+	// token signed with [Options.JWTKey].
+	TenantFromJWT
+)
+
+// Options configures the server started by [Start].
+type Options struct {
+	// AdminToken is the bearer token required by the /admin routes. When empty,
+	// every admin request is rejected.
+	AdminToken string
+
+	// TenantStrategy selects how the tenant of a request is resolved.
+	TenantStrategy TenantStrategy
+
+	// JWTKey is the HMAC key used to verify bearer tokens when TenantStrategy
+	// is [TenantFromJWT]. When empty, every token is rejected.
+	JWTKey []byte
+
+	// RateLimit is the rate at which each tenant's request budget refills.
+	// Zero disables rate limiting.
+	RateLimit rate.Limit
+
+	// RateBurst is the maximum budget a tenant can accumulate. Each request
+	// consumes the cost declared by its route.
+	RateBurst int
+
+	// TenantCacheTTL is how long a verified tenant is cached before its
+	// existence is checked again. Defaults to one minute.
+	TenantCacheTTL time.Duration
+
+	// OnboardingTimeout bounds the migration of a new tenant's schema. When it
+	// is exceeded the tenant is removed again and the request fails with 504.
+	// Zero means no limit.
+	OnboardingTimeout time.Duration
+
+	// MigrationConcurrency is the maximum number of tenant schemas migrated
+	// at once when migrating all tenants. Defaults to 4.
+	MigrationConcurrency int
+
+	// ReservedSchemas are schema names, in addition to the shared and system
+	// schemas, that tenants may never be onboarded to or offboarded from.
+	ReservedSchemas []string
+
+	// CounterReconcileInterval is how often the per-tenant book counters are
+	// checked against the actual number of books. Defaults to 10 minutes.
+	CounterReconcileInterval time.Duration
+
+	// Compression enables gzip compression of responses on routes that are
+	// not streamed.
+	Compression bool
+
+	// CompressionMinLength is the response size in bytes below which
+	// responses are sent uncompressed. Defaults to 1024.
+	CompressionMinLength int
+
+	// TenantLogSinks maps tenants to a dedicated sink for their slow query
+	// and error logs. Other tenants log to the database's logger.
+	TenantLogSinks map[string]io.Writer
+
+	// SlowQueryThreshold is the duration above which a query is logged to
+	// its tenant's sink. Defaults to 200ms.
+	SlowQueryThreshold time.Duration
+
+	// TierFields maps tenant tiers to the response fields, by JSON name,
+	// their tenants are entitled to on the book read endpoints. Tenants of
+	// other tiers get every field.
+	TierFields map[string][]string
+
+	// Exporter stores tenant exports, made on demand or before offboarding a
+	// tenant with ?archive=true. When nil, exports are rejected.
+	Exporter TenantExporter
+
+	// Logger receives structured logs, such as recovered panics. Defaults to
+	// [slog.Default].
+	Logger *slog.Logger
+
+	// Resolver returns the database holding each tenant's schema. Defaults
+	// to a [SchemaResolver] over the database passed to [Start].
+	Resolver TenantResolver
+
+	// SQLComments prefixes the statements of requests with a comment naming
+	// their tenant and request ID, see [EnableSQLComments]. Databases of a
+	// custom Resolver must be enabled separately.
+	SQLComments bool
+
+	// MigrationAttempts is how many times the migration of a new tenant's
+	// schema is attempted while it fails transiently. Defaults to 3.
+	MigrationAttempts int
+
+	// MigrationRetryDelay is the delay before the first retry of a new
+	// tenant's migration; it doubles on every retry. Defaults to 100ms.
+	MigrationRetryDelay time.Duration
+
+	// Auditor records the committed mutations of tenants and books.
+	// Defaults to a [SlogAuditLogger] over [Options.Logger].
+	Auditor AuditLogger
+
+	// ShutdownTimeout bounds the graceful shutdown. Defaults to 5 seconds.
+	ShutdownTimeout time.Duration
+
+	// StreamDrainTimeout bounds how long shutdown waits for streaming
+	// responses to end once asked to, within ShutdownTimeout. Zero means
+	// they may take all of it.
+	StreamDrainTimeout time.Duration
+
+	// ShutdownHooks run on shutdown, in order, once requests and background
+	// workers have stopped and before the database is closed.
+	ShutdownHooks []func(ctx context.Context) error
+
+	// CloseDB closes the database at the end of the shutdown.
+	CloseDB bool
+
+	// MaxInFlight is the maximum number of requests served at once; further
+	// requests are rejected with 503. Health probes are not counted. Zero
+	// means no limit.
+	MaxInFlight int
+
+	// DefaultPageSize is the number of items listed when a request does not
+	// set a limit. Defaults to 50.
+	DefaultPageSize int
+
+	// MaxPageSize caps the limit a request may set. Defaults to 100. Both
+	// page sizes may be overridden per tenant.
+	MaxPageSize int
+
+	// Location is the server's time zone, reported by GET /time. Defaults
+	// to UTC.
+	Location *time.Location
+
+	// Addr is the TCP address the server listens on. Defaults to ":8080".
+	Addr string
+
+	// DeletedBookPolicy decides whether creating a book with the ISBN of a
+	// soft-deleted one restores it or creates a new book.
+	DeletedBookPolicy DeletedBookPolicy
+
+	// TenantQueryTimeout bounds the query of each tenant in cross-tenant
+	// reports; a tenant exceeding it is reported as failed. Defaults to 5
+	// seconds.
+	TenantQueryTimeout time.Duration
+
+	// SigningKey, when set, is the HMAC key destructive routes, such as
+	// deleting a tenant, must be signed with, see [SignRequest]. Each signed
+	// request is only accepted once, within SignatureMaxAge of its timestamp.
+	SigningKey []byte
+
+	// SignatureMaxAge is how far the timestamp of a signed request may be
+	// from the server's clock. Defaults to 5 minutes.
+	SignatureMaxAge time.Duration
+
+	// BasePath is the prefix every route is mounted under, such as "/api/v1".
+	// Defaults to the root.
+	BasePath string
+
+	// IdempotencyTTL is how long the response of a request sent with an
+	// Idempotency-Key is replayed for repeats of the request. Defaults to 24
+	// hours.
+	IdempotencyTTL time.Duration
+
+	// HandlerTimeout bounds how long a request may take, failing it with 504
+	// when its handler runs out of time. It should be below the server's
+	// write timeout of 10 seconds. Streamed routes are not bounded. Zero
+	// means no limit.
+	HandlerTimeout time.Duration
+
+	// DisableAutoMigrate creates tenants without their schema, as pending
+	// tenants that are not served until POST /tenants/:id/migrate creates
+	// it.
+	DisableAutoMigrate bool
+
+	// PrepareStatements prepares the statements of tenant queries and caches
+	// them per tenant schema, so a statement prepared for one tenant is never
+	// run for another. Each schema holds its own statements open, so the
+	// number of prepared statements grows with the number of tenants. It has
+	// no effect with SQLComments, which make every statement unique.
+	PrepareStatements bool
+
+	// ReplayCreatedAsOK replays the 201 response of a create repeated with
+	// the same Idempotency-Key as 200, for clients telling a new resource
+	// from an existing one by the status. By default the original 201 is
+	// replayed.
+	ReplayCreatedAsOK bool
+
+	// BodyLogging logs the JSON request and response bodies of the routes
+	// that are not streamed, for debugging. Off by default.
+	BodyLogging bool
+
+	// BodyLogLimit is the size in bytes above which a body is not logged,
+	// only its size. Defaults to 4096.
+	BodyLogLimit int
+
+	// RedactedFields are JSON fields whose values are replaced with "***"
+	// in logged bodies, in addition to password, token, secret and apiKey.
+	// Names are matched case-insensitively.
+	RedactedFields []string
+
+	// MaxBodyBytes is the maximum size in bytes of a request body; larger
+	// ones are rejected with 413. Zero means no limit.
+	MaxBodyBytes int64
+
+	// BodyBudget is the maximum number of bytes of the request bodies being
+	// read at once, across all requests; requests beyond it are rejected
+	// with 503. Zero means no limit.
+	BodyBudget int64
+
+	// ReadTimeout bounds reading a request, body included. Defaults to 5
+	// seconds.
+	ReadTimeout time.Duration
+
+	// WriteTimeout bounds writing a response, from the end of the request
+	// headers. Defaults to 10 seconds.
+	WriteTimeout time.Duration
+
+	// SelfTest onboards and offboards a throwaway tenant on startup, with a
+	// book created and read back, and fails the start if any step fails.
+	// Meant for staging. Off by default.
+	SelfTest bool
+
+	// JanitorInterval is how often orphaned tenant schemas, and the rows of
+	// tenants whose schema is missing, are cleaned up in the background.
+	// Zero, the default, disables the janitor.
+	JanitorInterval time.Duration
+
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure the pool of
+	// database connections on startup. Zero leaves a setting at the
+	// driver's default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// TracerProvider provides the tracer of the spans of requests, tenant
+	// resolutions, migrations and database calls, see [EnableTracing].
+	// Databases of a custom Resolver must be enabled separately. Defaults to
+	// a no-op provider, tracing nothing.
+	TracerProvider trace.TracerProvider
+}
+
+// Option configures [Options].
+type Option func(*Options)
+
+// WithAdminToken sets the bearer token guarding the /admin routes.
+func WithAdminToken(token string) Option {
+	return func(o *Options) {
+		o.AdminToken = token
+	}
+}
+
+// WithTenantStrategy selects how the tenant of a request is resolved.
+func WithTenantStrategy(strategy TenantStrategy) Option {
+	return func(o *Options) {
+		o.TenantStrategy = strategy
+	}
+}
+
+// WithJWTKey sets the HMAC key used to verify bearer tokens when resolving
+// tenants with [TenantFromJWT].
+func WithJWTKey(key []byte) Option {
+	return func(o *Options) {
+		o.JWTKey = key
+	}
+}
+
+// WithRateLimit enables per-tenant rate limiting, refilling each tenant's
+// budget at limit units per second up to burst.
+func WithRateLimit(limit float64, burst int) Option {
+	return func(o *Options) {
+		o.RateLimit = rate.Limit(limit)
+		o.RateBurst = burst
+	}
+}
+
+// WithTenantCacheTTL sets how long verified tenants are cached.
+func WithTenantCacheTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TenantCacheTTL = ttl
+	}
+}
+
+// WithOnboardingTimeout bounds how long creating a tenant may spend migrating
+// its schema.
+func WithOnboardingTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.OnboardingTimeout = timeout
+	}
+}
+
+// WithMigrationConcurrency sets how many tenant schemas are migrated at once
+// when migrating all tenants.
+func WithMigrationConcurrency(n int) Option {
+	return func(o *Options) {
+		o.MigrationConcurrency = n
+	}
+}
+
+// WithReservedSchemas protects additional schemas from being used or dropped
+// as tenant schemas.
+func WithReservedSchemas(names ...string) Option {
+	return func(o *Options) {
+		o.ReservedSchemas = append(o.ReservedSchemas, names...)
+	}
+}
+
+// WithCounterReconcileInterval sets how often the book counters are
+// reconciled.
+func WithCounterReconcileInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.CounterReconcileInterval = interval
+	}
+}
+
+// WithCompression enables gzip compression of responses of at least
+// minLength bytes; zero selects the default threshold.
+func WithCompression(minLength int) Option {
+	return func(o *Options) {
+		o.Compression = true
+		o.CompressionMinLength = minLength
+	}
+}
+
+// WithTenantLogSink routes the slow query and error logs of tenant to w.
+func WithTenantLogSink(tenant string, w io.Writer) Option {
+	return func(o *Options) {
+		if o.TenantLogSinks == nil {
+			o.TenantLogSinks = make(map[string]io.Writer)
+		}
+		o.TenantLogSinks[tenant] = w
+	}
+}
+
+// WithSlowQueryThreshold sets the duration above which queries are logged to
+// tenant log sinks.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(o *Options) {
+		o.SlowQueryThreshold = threshold
+	}
+}
+
+// WithTierFields restricts the book fields returned to tenants of tier to
+// the given JSON field names.
+func WithTierFields(tier string, fields ...string) Option {
+	return func(o *Options) {
+		if o.TierFields == nil {
+			o.TierFields = make(map[string][]string)
+		}
+		o.TierFields[tier] = fields
+	}
+}
+
+// WithTenantExporter sets where tenant exports are stored.
+func WithTenantExporter(x TenantExporter) Option {
+	return func(o *Options) {
+		o.Exporter = x
+	}
+}
+
+// WithLogger sets the structured logger.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithTenantResolver sets how the database holding a tenant's schema is
+// found, e.g. to give some tenants a dedicated database.
+func WithTenantResolver(r TenantResolver) Option {
+	return func(o *Options) {
+		o.Resolver = r
+	}
+}
+
+// WithSQLComments tags the statements of requests with their tenant and
+// request ID.
+func WithSQLComments() Option {
+	return func(o *Options) {
+		o.SQLComments = true
+	}
+}
+
+// WithMigrationRetry sets how many times a new tenant's migration is
+// attempted while it fails transiently, and the initial delay between
+// attempts.
+func WithMigrationRetry(attempts int, baseDelay time.Duration) Option {
+	return func(o *Options) {
+		o.MigrationAttempts = attempts
+		o.MigrationRetryDelay = baseDelay
+	}
+}
+
+// WithAuditLogger sets where mutations of tenants and books are recorded.
+func WithAuditLogger(l AuditLogger) Option {
+	return func(o *Options) {
+		o.Auditor = l
+	}
+}
+
+// WithShutdownTimeout bounds the graceful shutdown.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ShutdownTimeout = timeout
+	}
+}
+
+// WithStreamDrainTimeout bounds how long shutdown waits for streaming
+// responses to end.
+func WithStreamDrainTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.StreamDrainTimeout = timeout
+	}
+}
+
+// WithShutdownHook adds a function run on shutdown, after requests and
+// background workers have stopped and before the database is closed.
+func WithShutdownHook(hook func(ctx context.Context) error) Option {
+	return func(o *Options) {
+		o.ShutdownHooks = append(o.ShutdownHooks, hook)
+	}
+}
+
+// WithCloseDB closes the database once the server has shut down.
+func WithCloseDB() Option {
+	return func(o *Options) {
+		o.CloseDB = true
+	}
+}
+
+// WithMaxInFlight caps the number of requests served at once.
+func WithMaxInFlight(n int) Option {
+	return func(o *Options) {
+		o.MaxInFlight = n
+	}
+}
+
+// WithPageSize sets the default and maximum number of items listed per
+// request, for tenants without overrides of their own.
+func WithPageSize(size, maxSize int) Option {
+	return func(o *Options) {
+		o.DefaultPageSize = size
+		o.MaxPageSize = maxSize
+	}
+}
+
+// WithLocation sets the server's time zone.
+func WithLocation(loc *time.Location) Option {
+	return func(o *Options) {
+		o.Location = loc
+	}
+}
+
+// WithAddr sets the TCP address the server listens on.
+func WithAddr(addr string) Option {
+	return func(o *Options) {
+		o.Addr = addr
+	}
+}
+
+// WithDeletedBookPolicy sets what creating a book with the ISBN of a
+// soft-deleted one does.
+func WithDeletedBookPolicy(p DeletedBookPolicy) Option {
+	return func(o *Options) {
+		o.DeletedBookPolicy = p
+	}
+}
+
+// WithTenantQueryTimeout bounds the query of each tenant in cross-tenant
+// reports.
+func WithTenantQueryTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.TenantQueryTimeout = timeout
+	}
+}
+
+// WithRequestSigning requires destructive routes to be signed with key, at
+// most maxAge from the server's clock; zero selects the default.
+func WithRequestSigning(key []byte, maxAge time.Duration) Option {
+	return func(o *Options) {
+		o.SigningKey = key
+		o.SignatureMaxAge = maxAge
+	}
+}
+
+// WithBasePath mounts every route under prefix, e.g. to version the API or
+// run behind a gateway forwarding a path prefix.
+func WithBasePath(prefix string) Option {
+	return func(o *Options) {
+		o.BasePath = prefix
+	}
+}
+
+// WithIdempotencyTTL sets how long the responses of requests sent with an
+// Idempotency-Key are kept for replay.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.IdempotencyTTL = ttl
+	}
+}
+
+// WithHandlerTimeout bounds how long a request may take. Handlers are
+// bounded through their request context, which their queries honor.
+func WithHandlerTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.HandlerTimeout = d
+	}
+}
+
+// WithAutoMigrate sets whether creating a tenant migrates its schema, which
+// is the default. When disabled the tenant is created pending, and its
+// onboarding is completed by migrating it.
+func WithAutoMigrate(enabled bool) Option {
+	return func(o *Options) {
+		o.DisableAutoMigrate = !enabled
+	}
+}
+
+// WithPreparedStatements caches the prepared statements of tenant queries
+// per tenant schema.
+func WithPreparedStatements() Option {
+	return func(o *Options) {
+		o.PrepareStatements = true
+	}
+}
+
+// WithReplayCreatedAsOK replays creates repeated with the same
+// Idempotency-Key with 200 rather than the original 201.
+func WithReplayCreatedAsOK() Option {
+	return func(o *Options) {
+		o.ReplayCreatedAsOK = true
+	}
+}
+
+// WithBodyLogging sets whether the JSON request and response bodies are
+// logged, with the given fields redacted in addition to the default ones.
+func WithBodyLogging(enabled bool, redactedFields ...string) Option {
+	return func(o *Options) {
+		o.BodyLogging = enabled
+		o.RedactedFields = append(o.RedactedFields, redactedFields...)
+	}
+}
+
+// WithBodyLogLimit sets the size in bytes above which bodies are not logged.
+func WithBodyLogLimit(n int) Option {
+	return func(o *Options) {
+		o.BodyLogLimit = n
+	}
+}
+
+// WithBodyLimits caps the size of each request body to perRequest bytes and
+// of all the request bodies being read at once to total bytes. Zero leaves a
+// limit unset.
+func WithBodyLimits(perRequest, total int64) Option {
+	return func(o *Options) {
+		o.MaxBodyBytes = perRequest
+		o.BodyBudget = total
+	}
+}
+
+// WithServerTimeouts bounds reading requests and writing responses.
+func WithServerTimeouts(read, write time.Duration) Option {
+	return func(o *Options) {
+		o.ReadTimeout = read
+		o.WriteTimeout = write
+	}
+}
+
+// WithSelfTest sets whether the server proves it can onboard, serve and
+// offboard a tenant before accepting traffic.
+func WithSelfTest(enabled bool) Option {
+	return func(o *Options) {
+		o.SelfTest = enabled
+	}
+}
+
+// WithJanitorInterval enables the background cleanup of what partially
+// failed offboardings left behind, running it at the given interval.
+func WithJanitorInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.JanitorInterval = interval
+	}
+}
+
+// WithDBPool bounds the pool of database connections to maxOpen connections,
+// of which up to maxIdle are kept idle, each reused for at most
+// connMaxLifetime. Zero leaves a setting at the driver's default.
+func WithDBPool(maxOpen, maxIdle int, connMaxLifetime time.Duration) Option {
+	return func(o *Options) {
+		o.MaxOpenConns = maxOpen
+		o.MaxIdleConns = maxIdle
+		o.ConnMaxLifetime = connMaxLifetime
+	}
+}
+
+// WithTracerProvider traces the server's work with the spans of tp.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *Options) {
+		o.TracerProvider = tp
+	}
+}
@@ -0,0 +1,117 @@
+package echoserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPageSize    = 50
+	defaultMaxPageSize = 100
+)
+
+// HeaderXNextCursor carries the cursor of the next page of a list, when
+// there is one. Passing it as the after query parameter continues the list
+// from the last item of the page.
+const HeaderXNextCursor = "X-Next-Cursor"
+
+// page is the slice of a list requested with the limit query parameter and
+// either the offset or the after query parameter.
+type page struct {
+	limit  int
+	offset int
+	after  uint // after is the ID the page starts after, in keyset mode.
+}
+
+// cursor is the position of a list item. It is encoded as opaque base64 JSON,
+// so the sort key can change without breaking clients' URLs.
+type cursor struct {
+	ID uint `json:"id"`
+}
+
+func encodeCursor(cur cursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var cur cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &cur)
+	}
+	return cur, err
+}
+
+// setNextCursor reports the cursor continuing the list after id.
+func setNextCursor(c echo.Context, id uint) {
+	c.Response().Header().Set(HeaderXNextCursor, encodeCursor(cursor{ID: id}))
+}
+
+// pageSizes returns the default and maximum page sizes of the tenant: its
+// own overrides, falling back to [Options.DefaultPageSize] and
+// [Options.MaxPageSize].
+func (cr *controller) pageSizes(ctx context.Context, tenantID string) (size, maxSize int, err error) {
+	size, maxSize = cr.opts.DefaultPageSize, cr.opts.MaxPageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxPageSize
+	}
+	var overrides []struct{ DefaultPageSize, MaxPageSize int }
+	if err = cr.db.WithContext(ctx).Model(&models.Tenant{}).
+		Select("default_page_size", "max_page_size").
+		Where("schema_name = ?", tenantID).Limit(1).
+		Find(&overrides).Error; err != nil {
+		return 0, 0, err
+	}
+	if len(overrides) > 0 {
+		if o := overrides[0]; o.MaxPageSize > 0 {
+			maxSize = o.MaxPageSize
+		}
+		if o := overrides[0]; o.DefaultPageSize > 0 {
+			size = o.DefaultPageSize
+		}
+	}
+	return min(size, maxSize), maxSize, nil
+}
+
+// parsePage parses the limit, offset and after query parameters against the
+// page sizes of the tenant. A limit above the tenant's maximum is capped to
+// it.
+func (cr *controller) parsePage(c echo.Context, tenantID string) (page, error) {
+	size, maxSize, err := cr.pageSizes(c.Request().Context(), tenantID)
+	if err != nil {
+		return page{}, err
+	}
+	p := page{limit: size}
+	if s := c.QueryParam("limit"); s != "" {
+		if p.limit, err = strconv.Atoi(s); err != nil || p.limit < 1 {
+			return page{}, echo.NewHTTPError(http.StatusBadRequest, "limit must be a positive integer")
+		}
+		p.limit = min(p.limit, maxSize)
+	}
+	if s := c.QueryParam("offset"); s != "" {
+		if p.offset, err = strconv.Atoi(s); err != nil || p.offset < 0 {
+			return page{}, echo.NewHTTPError(http.StatusBadRequest, "offset must be a non-negative integer")
+		}
+	}
+	if s := c.QueryParam("after"); s != "" {
+		if c.QueryParam("offset") != "" {
+			return page{}, echo.NewHTTPError(http.StatusBadRequest, "after and offset are mutually exclusive")
+		}
+		cur, err := decodeCursor(s)
+		if err != nil || cur.ID == 0 {
+			return page{}, echo.NewHTTPError(http.StatusBadRequest, "after must be a cursor returned in "+HeaderXNextCursor)
+		}
+		p.after = cur.ID
+	}
+	return p, nil
+}
@@ -0,0 +1,102 @@
+package echoserver
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagination(t *testing.T) {
+	_, e := newSQLiteServer(t, WithPageSize(2, 3))
+	tenants := map[string]string{
+		"standard": `{"domainUrl": "tenant1.example.com"}`,
+		"premium":  `{"domainUrl": "tenant2.example.com", "defaultPageSize": 4, "maxPageSize": 5}`,
+	}
+	for name, body := range tenants {
+		rr := serve(e, http.MethodPost, "/tenants", "", body)
+		require.Equal(t, http.StatusCreated, rr.Code, "%s: %s", name, rr.Body.String())
+	}
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		for i := 1; i <= 6; i++ {
+			rr := serve(e, http.MethodPost, "/books", host, fmt.Sprintf(`{"name": "Book %d", "author": "Author"}`, i))
+			require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name  string
+		host  string
+		query string
+		want  []uint
+	}{
+		{"StandardDefault", "tenant1.example.com", "", []uint{1, 2}},
+		{"StandardCapped", "tenant1.example.com", "?limit=5", []uint{1, 2, 3}},
+		{"StandardOffset", "tenant1.example.com", "?limit=2&offset=4", []uint{5, 6}},
+		{"PremiumDefault", "tenant2.example.com", "", []uint{1, 2, 3, 4}},
+		{"PremiumLarger", "tenant2.example.com", "?limit=5", []uint{1, 2, 3, 4, 5}},
+		{"PremiumCapped", "tenant2.example.com", "?limit=6", []uint{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(e, http.MethodGet, "/books"+tt.query, tt.host, "")
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			var ids []uint
+			for _, b := range decode[[]models.BookResponse](t, rr) {
+				ids = append(ids, b.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=x", "?offset=-1"} {
+		rr := serve(e, http.MethodGet, "/books"+query, "tenant1.example.com", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestKeysetPagination(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	for i := 1; i <= 8; i++ {
+		rr := serve(e, http.MethodPost, "/books", host, fmt.Sprintf(`{"name": "Book %d", "author": "Author"}`, i))
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var (
+		ids   []uint
+		pages int
+		path  = "/books?limit=3"
+	)
+	for {
+		rr := serve(e, http.MethodGet, path, host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		pages++
+		for _, b := range decode[[]models.BookResponse](t, rr) {
+			ids = append(ids, b.ID)
+		}
+		next := rr.Header().Get(HeaderXNextCursor)
+		if next == "" {
+			break
+		}
+		require.Less(t, pages, 10, "the walk ends")
+		path = "/books?limit=3&after=" + next
+	}
+	assert.Equal(t, []uint{1, 2, 3, 4, 5, 6, 7, 8}, ids, "every book is listed once, in order")
+	assert.Equal(t, 3, pages)
+
+	rr = serve(e, http.MethodGet, "/books?limit=8", host, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get(HeaderXNextCursor), "no cursor on the last page")
+
+	cur := encodeCursor(cursor{ID: 5})
+	for _, query := range []string{"?after=not-a-cursor", "?after=" + cur + "&offset=1"} {
+		rr := serve(e, http.MethodGet, "/books"+query, host, "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
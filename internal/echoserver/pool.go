@@ -0,0 +1,33 @@
+package echoserver
+
+import (
+	"log"
+)
+
+// configurePool applies the pool settings of the options to the database's
+// connections. Tenant switches stay confined to the connection they are made
+// on, as [withTenantTx] resets the tenant before the connection goes back to
+// the pool, so idle connections are always on the shared schema and may be
+// pooled freely.
+func (cr *controller) configurePool() error {
+	o := cr.opts
+	if o.MaxOpenConns <= 0 && o.MaxIdleConns <= 0 && o.ConnMaxLifetime <= 0 {
+		return nil
+	}
+	sqlDB, err := cr.db.DB.DB()
+	if err != nil {
+		return err
+	}
+	if o.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+	log.Printf("Database pool: max open connections %d, max idle connections %d, max lifetime %s",
+		o.MaxOpenConns, o.MaxIdleConns, o.ConnMaxLifetime)
+	return nil
+}
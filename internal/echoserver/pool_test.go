@@ -0,0 +1,47 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBPool(t *testing.T) {
+	// The SQLite driver needs a single connection, which makes any tenant
+	// left in use on it observable by the next request.
+	cr, e := newSQLiteServer(t, WithDBPool(1, 1, time.Minute))
+	require.NoError(t, cr.configurePool())
+	sqlDB, err := cr.db.DB.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPut, "/books/1", host, `{"name": "Dune Messiah", "version": 1}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	stats := sqlDB.Stats()
+	assert.Equal(t, 1, stats.Idle, "the connection is back in the pool")
+	assert.Zero(t, stats.InUse)
+	assert.Empty(t, cr.db.CurrentTenant(context.Background()))
+
+	var used *multitenancy.DB
+	require.NoError(t, cr.withTenantTx(context.Background(), "tenant1", func(tx *multitenancy.DB) error {
+		used = tx
+		assert.Equal(t, "tenant1", tx.CurrentTenant(context.Background()))
+		return nil
+	}))
+	assert.Empty(t, used.CurrentTenant(context.Background()), "the tenant is reset before the connection is released")
+
+	rr = serve(e, http.MethodGet, "/books", host, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `[{"id": 1, "name": "Dune Messiah", "author": "Frank Herbert", "version": 2}]`, rr.Body.String())
+}
@@ -0,0 +1,85 @@
+package echoserver
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// stmtCaches holds a prepared statement cache per database and tenant
+// schema. GORM's own statement cache is keyed by the statement text alone,
+// but tenants share statement text whenever their schema is selected by
+// UseTenant's search_path rather than named in the statement, so one cache
+// for all tenants could run a statement prepared for one tenant's schema
+// against another's.
+//
+// The price is that every schema prepares its own statements: the number of
+// statements held open grows with the number of tenants, which matters on
+// databases with many small tenants.
+type stmtCaches struct {
+	mu     sync.Mutex
+	caches map[stmtCacheKey]*gorm.PreparedStmtDB
+}
+
+type stmtCacheKey struct {
+	pool   gorm.ConnPool
+	schema string
+}
+
+var _ Cache = (*stmtCaches)(nil)
+
+func newStmtCaches() *stmtCaches {
+	return &stmtCaches{caches: make(map[stmtCacheKey]*gorm.PreparedStmtDB)}
+}
+
+// get returns the statement cache of the schema on pool.
+func (s *stmtCaches) get(pool gorm.ConnPool, schema string) *gorm.PreparedStmtDB {
+	// Never share the statements of a database-wide cache.
+	if p, ok := pool.(*gorm.PreparedStmtDB); ok {
+		pool = p.ConnPool
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := stmtCacheKey{pool: pool, schema: schema}
+	c, ok := s.caches[key]
+	if !ok {
+		c = gorm.NewPreparedStmtDB(pool)
+		s.caches[key] = c
+	}
+	return c
+}
+
+// reset closes the statements prepared for the schema, which must be done
+// once its tables change or it is dropped. Statements in use are closed once
+// their queries finish.
+func (s *stmtCaches) reset(schema string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, c := range s.caches {
+		if key.schema == schema {
+			c.Reset()
+		}
+	}
+}
+
+// Stats implements [Cache]. Its size is the number of prepared statements.
+func (s *stmtCaches) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stats CacheStats
+	for _, c := range s.caches {
+		c.Mux.RLock()
+		stats.Size += len(c.Stmts)
+		c.Mux.RUnlock()
+	}
+	return stats
+}
+
+// Clear implements [Cache].
+func (s *stmtCaches) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.caches {
+		c.Reset()
+	}
+}
@@ -0,0 +1,74 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPreparedStatements(t *testing.T) {
+	const token = "admin-secret"
+	cr, e := newSQLiteServer(t, WithPreparedStatements(), WithAdminToken(token))
+	require.NotNil(t, cr.stmts)
+	tenants := []string{"tenant1", "tenant2"}
+	for _, tenant := range tenants {
+		host := tenant + ".example.com"
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodPost, "/books", host, `{"name": "`+tenant+` book", "author": "Author"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	cache := func(tenant string) *gorm.PreparedStmtDB {
+		return cr.stmts.get(cr.db.Statement.ConnPool, tenant)
+	}
+
+	t.Run("SameStatementPerTenant", func(t *testing.T) {
+		const query = "SELECT ?"
+		for range 2 {
+			for _, tenant := range tenants {
+				db, err := cr.tenantDB(context.Background(), tenant)
+				require.NoError(t, err)
+				var got string
+				require.NoError(t, db.Raw(query, tenant).Scan(&got).Error)
+				assert.Equal(t, tenant, got)
+			}
+		}
+		one, two := cache("tenant1"), cache("tenant2")
+		require.NotSame(t, one, two, "each schema has its own cache")
+		require.Contains(t, one.Stmts, query)
+		require.Contains(t, two.Stmts, query)
+		assert.NotSame(t, one.Stmts[query].Stmt, two.Stmts[query].Stmt,
+			"a statement prepared for one tenant is never reused for another")
+	})
+
+	t.Run("TenantsSeeTheirOwnBooks", func(t *testing.T) {
+		for range 2 {
+			for _, tenant := range tenants {
+				rr := serve(e, http.MethodGet, "/books", tenant+".example.com", "")
+				require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				books := decode[[]models.BookResponse](t, rr)
+				require.Len(t, books, 1)
+				assert.Equal(t, tenant+" book", books[0].Name)
+			}
+		}
+		assert.Positive(t, cr.stmts.Stats().Size)
+	})
+
+	t.Run("MigrationResetsStatements", func(t *testing.T) {
+		require.NotEmpty(t, cache("tenant1").Stmts)
+		rr := serveAdmin(e, http.MethodPost, "/tenants/1/migrate", token)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Empty(t, cache("tenant1").Stmts)
+		assert.NotEmpty(t, cache("tenant2").Stmts, "other tenants keep their statements")
+	})
+}
+
+func TestPreparedStatementsWithSQLComments(t *testing.T) {
+	cr, _ := newSQLiteServer(t, WithPreparedStatements(), WithSQLComments())
+	assert.Nil(t, cr.stmts, "commented statements are never reused, so they are not cached")
+}
@@ -0,0 +1,80 @@
+package echoserver
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// tenantLimiter holds a token bucket per tenant. Requests draw from their
+// tenant's bucket according to the cost of the route they hit, so expensive
+// operations consume more of the quota than cheap ones.
+type tenantLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newTenantLimiter(limit rate.Limit, burst int) *tenantLimiter {
+	return &tenantLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+func (l *tenantLimiter) limiter(tenant string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lim, ok := l.limiters[tenant]
+	if !ok {
+		lim = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[tenant] = lim
+	}
+	return lim
+}
+
+// allow deducts cost from the tenant's budget. If the budget is insufficient
+// nothing is deducted and the time until it would be is returned.
+func (l *tenantLimiter) allow(tenant string, cost int, now time.Time) (bool, time.Duration) {
+	r := l.limiter(tenant).ReserveN(now, cost)
+	if !r.OK() {
+		return false, 0 // cost exceeds the burst, it can never be allowed
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// rateLimit returns a middleware charging cost against the budget of the
+// request's tenant, responding 429 when the budget is exhausted.
+func (cr *controller) rateLimit(cost int) echo.MiddlewareFunc {
+	if cost <= 0 {
+		cost = 1
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenantID, err := TenantFromContext(c)
+			if err != nil {
+				return next(c)
+			}
+			ok, retryAfter := cr.limits.allow(tenantID, cost, time.Now())
+			if !ok {
+				if retryAfter > 0 {
+					c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				}
+				return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+			return next(c)
+		}
+	}
+}
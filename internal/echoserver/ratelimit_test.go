@@ -0,0 +1,51 @@
+package echoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedRateLimit(t *testing.T) {
+	cr := &controller{
+		opts:    Options{RateLimit: 0.001, RateBurst: 10},
+		tenants: staticTenants("tenant1", "tenant2"),
+	}
+	e := echo.New()
+	cr.init(e)
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/cheap", ok, cr.rateLimit(1))
+	e.GET("/expensive", ok, cr.rateLimit(5))
+
+	// served counts the requests admitted before the tenant's budget runs out.
+	served := func(path, host string) int {
+		n := 0
+		for range 20 {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Host = host
+			rr := httptest.NewRecorder()
+			e.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+				assert.NotEmpty(t, rr.Header().Get(echo.HeaderRetryAfter))
+				break
+			}
+			n++
+		}
+		return n
+	}
+
+	assert.Equal(t, 10, served("/cheap", "tenant1.example.com"))
+	assert.Equal(t, 2, served("/expensive", "tenant2.example.com"))
+	assert.Zero(t, served("/cheap", "tenant1.example.com"), "budget is shared across routes")
+}
+
+func TestTenantLimiterCostAboveBurst(t *testing.T) {
+	l := newTenantLimiter(1, 3)
+	ok, _ := l.allow("tenant1", 4, time.Now())
+	assert.False(t, ok)
+}
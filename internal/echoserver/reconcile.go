@@ -0,0 +1,159 @@
+package echoserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// reconcileHandler reports drift between tenant rows and tenant schemas:
+// schemas no tenant row points to, and rows whose schema is missing. With
+// ?apply=true the orphaned schemas are dropped. Rows with a missing schema
+// are only reported, since recreating the schema cannot restore its data.
+// Only the shared database is reconciled; tenants with a dedicated database
+// are left alone.
+func (cr *controller) reconcileHandler(c echo.Context) error {
+	apply := false
+	if v := c.QueryParam("apply"); v != "" {
+		var err error
+		if apply, err = strconv.ParseBool(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "apply must be a boolean")
+		}
+	}
+	ctx := c.Request().Context()
+	if apply {
+		// Let a janitor pass in progress finish first.
+		cr.reconciling.Lock()
+		defer cr.reconciling.Unlock()
+	}
+	orphaned, missing, err := cr.findDrift(ctx)
+	if err != nil {
+		return err
+	}
+	res := models.ReconcileResponse{
+		Applied:         apply,
+		OrphanedSchemas: orphaned,
+		MissingSchemas:  missing,
+		Dropped:         []string{},
+	}
+	if apply {
+		res.Dropped, res.Failed = cr.dropOrphanedSchemas(ctx, orphaned)
+	}
+	return respond(c, http.StatusOK, res)
+}
+
+// findDrift returns, sorted, the schemas of the shared database no tenant
+// row points to, and the onboarded tenants whose schema is missing from it.
+func (cr *controller) findDrift(ctx context.Context) (orphaned, missing []string, err error) {
+	var rows, onboarded []string
+	if err = cr.db.WithContext(ctx).Model(&models.Tenant{}).Pluck("schema_name", &rows).Error; err != nil {
+		return nil, nil, err
+	}
+	// Pending tenants have no schema until they are migrated.
+	if err = cr.db.WithContext(ctx).Model(&models.Tenant{}).Scopes(onboardedTenants).Pluck("schema_name", &onboarded).Error; err != nil {
+		return nil, nil, err
+	}
+	schemas, err := cr.tenantSchemas(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	orphaned, missing = []string{}, []string{}
+	for _, schema := range schemas {
+		if !slices.Contains(rows, schema) {
+			orphaned = append(orphaned, schema)
+		}
+	}
+	for _, row := range onboarded {
+		if !slices.Contains(schemas, row) {
+			missing = append(missing, row)
+		}
+	}
+	if orphaned, err = cr.sharedSchemas(ctx, orphaned); err != nil {
+		return nil, nil, err
+	}
+	if missing, err = cr.sharedSchemas(ctx, missing); err != nil {
+		return nil, nil, err
+	}
+	slices.Sort(orphaned)
+	slices.Sort(missing)
+	return orphaned, missing, nil
+}
+
+// dropOrphanedSchemas drops the given orphaned schemas and their book
+// counters, returning those dropped and the reasons the others were not.
+func (cr *controller) dropOrphanedSchemas(ctx context.Context, schemas []string) (dropped []string, failed map[string]string) {
+	dropped = []string{}
+	for _, schema := range schemas {
+		if err := cr.offboardTenant(ctx, schema); err != nil {
+			log.Printf("Failed to drop orphaned schema %q: %v", schema, err)
+			if failed == nil {
+				failed = make(map[string]string)
+			}
+			failed[schema] = categoryInfo[classifyError(err)].message
+			continue
+		}
+		cr.tenants.Remove(schema)
+		if err := cr.db.WithContext(ctx).Delete(&models.BookCounter{}, "tenant_schema = ?", schema).Error; err != nil {
+			log.Printf("Failed to delete book counter of orphaned schema %q: %v", schema, err)
+		}
+		dropped = append(dropped, schema)
+	}
+	return dropped, failed
+}
+
+// tenantSchemas lists the schemas holding tenant tables, identified by the
+// books table, so unrelated schemas on the same server are never mistaken
+// for orphaned tenants. Reserved schemas are excluded.
+func (cr *controller) tenantSchemas(ctx context.Context) ([]string, error) {
+	db := cr.db.WithContext(ctx)
+	var schemas []string
+	switch db.Dialector.Name() {
+	case "sqlite":
+		// Each schema is an attached database, see initdb.
+		var attached []struct{ Name string }
+		if err := db.Raw("PRAGMA database_list").Scan(&attached).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range attached {
+			var n int64
+			if err := db.Raw(`SELECT count(*) FROM "`+a.Name+`".sqlite_master WHERE type = 'table' AND name = ?`,
+				models.TableNameBook).Scan(&n).Error; err != nil {
+				return nil, err
+			}
+			if n > 0 {
+				schemas = append(schemas, a.Name)
+			}
+		}
+	default:
+		if err := db.Raw("SELECT DISTINCT table_schema FROM information_schema.tables WHERE table_name = ?",
+			models.TableNameBook).Scan(&schemas).Error; err != nil {
+			return nil, err
+		}
+	}
+	return slices.DeleteFunc(schemas, func(schema string) bool {
+		return cr.isReservedSchema(schema) || strings.HasPrefix(schema, "pg_")
+	}), nil
+}
+
+// sharedSchemas returns the schemas of tenants whose schema lives in the
+// shared database.
+func (cr *controller) sharedSchemas(ctx context.Context, schemas []string) ([]string, error) {
+	shared := []string{}
+	for _, schema := range schemas {
+		ok, err := cr.isShared(ctx, schema)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			shared = append(shared, schema)
+		}
+	}
+	return shared, nil
+}
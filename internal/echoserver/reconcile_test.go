@@ -0,0 +1,53 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+	const token = "admin-secret"
+	cr, e := newSQLiteServer(t, WithAdminToken(token))
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	// Seed drift: tenant2's row is lost, leaving its schema orphaned, and
+	// tenant3's row is created without a schema.
+	require.NoError(t, cr.db.Unscoped().Delete(&models.Tenant{}, "schema_name = ?", "tenant2").Error)
+	require.NoError(t, cr.db.Create(&models.Tenant{TenantModel: multitenancy.TenantModel{
+		DomainURL: "tenant3.example.com", SchemaName: "tenant3",
+	}}).Error)
+
+	rr := serveAdmin(e, http.MethodPost, "/admin/reconcile", token)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, models.ReconcileResponse{
+		OrphanedSchemas: []string{"tenant2"},
+		MissingSchemas:  []string{"tenant3"},
+		Dropped:         []string{},
+	}, decode[models.ReconcileResponse](t, rr), "drift is only reported by default")
+	schemas, err := cr.tenantSchemas(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tenant1", "tenant2"}, schemas)
+
+	rr = serveAdmin(e, http.MethodPost, "/admin/reconcile?apply=true", token)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, models.ReconcileResponse{
+		Applied:         true,
+		OrphanedSchemas: []string{"tenant2"},
+		MissingSchemas:  []string{"tenant3"},
+		Dropped:         []string{"tenant2"},
+	}, decode[models.ReconcileResponse](t, rr))
+	schemas, err = cr.tenantSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant1"}, schemas, "orphaned schema is dropped")
+
+	rr = serveAdmin(e, http.MethodPost, "/admin/reconcile?apply=maybe", token)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
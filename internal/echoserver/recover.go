@@ -0,0 +1,55 @@
+package echoserver
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// logger returns the structured logger, defaulting to [slog.Default].
+func (cr *controller) logger() *slog.Logger {
+	if cr.opts.Logger != nil {
+		return cr.opts.Logger
+	}
+	return slog.Default()
+}
+
+// recoverJSON returns a middleware recovering from panics in later handlers.
+// The panic is logged with its stack trace and the request ID, and the client
+// receives a generic [models.ErrorResponse], never the panic or the stack.
+func (cr *controller) recoverJSON() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if e, ok := r.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+					panic(r) // let net/http abort the response
+				}
+				cr.logger().ErrorContext(c.Request().Context(), "Recovered from panic",
+					"request_id", c.Response().Header().Get(echo.HeaderXRequestID),
+					"method", c.Request().Method,
+					"path", c.Request().URL.Path,
+					"panic", fmt.Sprint(r),
+					"stack", string(debug.Stack()),
+				)
+				if c.Response().Committed {
+					return
+				}
+				info := categoryInfo[CategoryInternal]
+				err = respond(c, info.status, models.ErrorResponse{
+					Message:  info.message,
+					Category: string(CategoryInternal),
+				})
+			}()
+			return next(c)
+		}
+	}
+}
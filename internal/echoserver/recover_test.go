@@ -0,0 +1,43 @@
+package echoserver
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverJSON(t *testing.T) {
+	var logs bytes.Buffer
+	_, e := newServer(nil, WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	e.GET("/admin/panic", func(echo.Context) error {
+		panic("database password is hunter2")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/panic", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-42")
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "req-42", rr.Header().Get(echo.HeaderXRequestID), "request ID round-trips")
+	assert.Equal(t, echo.MIMEApplicationJSON, rr.Header().Get(echo.HeaderContentType))
+	assert.JSONEq(t, `{"message": "internal server error", "category": "internal"}`, rr.Body.String())
+	assert.NotContains(t, rr.Body.String(), "hunter2", "panic is not leaked")
+	assert.NotContains(t, rr.Body.String(), "goroutine", "stack is not leaked")
+
+	assert.Contains(t, logs.String(), `"request_id":"req-42"`)
+	assert.Contains(t, logs.String(), "hunter2", "panic is logged")
+	assert.Contains(t, logs.String(), "recover.go", "stack is logged")
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	_, e := newServer(nil)
+	rr := serve(e, http.MethodGet, "/openapi.json", "", "")
+	assert.NotEmpty(t, rr.Header().Get(echo.HeaderXRequestID))
+}
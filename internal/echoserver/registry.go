@@ -0,0 +1,204 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// defaultTenantCacheTTL is how long a known tenant is trusted before its
+// existence is checked against the database again.
+const defaultTenantCacheTTL = time.Minute
+
+// TenantLookup reports whether a tenant with the given schema name exists.
+type TenantLookup func(ctx context.Context, schemaName string) (bool, error)
+
+// TenantStatusLookup returns the status of the tenant with the given schema
+// name, such as [models.TenantActive], or "" if there is no such tenant.
+type TenantStatusLookup func(ctx context.Context, schemaName string) (string, error)
+
+// TenantRegistry is a read-through cache of known tenant schema names and
+// their statuses, so verifying a tenant does not cost a database query on
+// every request. Only positive lookups are cached; entries expire after the
+// configured TTL, which bounds how long another server may take to notice a
+// tenant's status changed.
+type TenantRegistry struct {
+	ttl    time.Duration
+	lookup TenantStatusLookup
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	entries map[string]registryEntry // schema name -> entry
+
+	hits, misses atomic.Uint64
+}
+
+type registryEntry struct {
+	status string
+	expiry time.Time
+}
+
+var _ Cache = (*TenantRegistry)(nil)
+
+// NewTenantRegistry returns a registry caching the positive results of lookup
+// for ttl. Every tenant it finds is active.
+func NewTenantRegistry(ttl time.Duration, lookup TenantLookup) *TenantRegistry {
+	return NewTenantStatusRegistry(ttl, func(ctx context.Context, schemaName string) (string, error) {
+		exists, err := lookup(ctx, schemaName)
+		if err != nil || !exists {
+			return "", err
+		}
+		return models.TenantActive, nil
+	})
+}
+
+// NewTenantStatusRegistry returns a registry caching the statuses of the
+// tenants found by lookup for ttl.
+func NewTenantStatusRegistry(ttl time.Duration, lookup TenantStatusLookup) *TenantRegistry {
+	return &TenantRegistry{
+		ttl:     ttl,
+		lookup:  lookup,
+		now:     time.Now,
+		entries: make(map[string]registryEntry),
+	}
+}
+
+// Exists reports whether the tenant exists, whatever its status, consulting
+// the lookup on a cache miss.
+func (r *TenantRegistry) Exists(ctx context.Context, schemaName string) (bool, error) {
+	status, err := r.Status(ctx, schemaName)
+	return status != "", err
+}
+
+// Status returns the status of the tenant, or "" if it does not exist,
+// consulting the lookup on a cache miss.
+func (r *TenantRegistry) Status(ctx context.Context, schemaName string) (string, error) {
+	r.mu.RLock()
+	entry, ok := r.entries[schemaName]
+	r.mu.RUnlock()
+	if ok && r.now().Before(entry.expiry) {
+		r.hits.Add(1)
+		return entry.status, nil
+	}
+	r.misses.Add(1)
+	status, err := r.lookup(ctx, schemaName)
+	if err != nil {
+		return "", err
+	}
+	if status != "" {
+		r.SetStatus(schemaName, status)
+	} else if ok {
+		r.Remove(schemaName)
+	}
+	return status, nil
+}
+
+// Add records the tenant as known to exist and be active.
+func (r *TenantRegistry) Add(schemaName string) {
+	r.SetStatus(schemaName, models.TenantActive)
+}
+
+// SetStatus records the tenant's status, e.g. after it has been suspended.
+func (r *TenantRegistry) SetStatus(schemaName, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[schemaName] = registryEntry{status: status, expiry: r.now().Add(r.ttl)}
+}
+
+// Remove evicts the tenant, e.g. after it has been offboarded.
+func (r *TenantRegistry) Remove(schemaName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, schemaName)
+}
+
+// Stats implements [Cache].
+func (r *TenantRegistry) Stats() CacheStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+		Size:   len(r.entries),
+	}
+}
+
+// Clear implements [Cache].
+func (r *TenantRegistry) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.entries)
+}
+
+// onboardedTenants scopes a query of tenants to those with a schema; pending
+// tenants have none until they are migrated.
+func onboardedTenants(db *gorm.DB) *gorm.DB {
+	return db.Where("status <> ?", models.TenantPending)
+}
+
+// tenantStatus returns the status of the tenant with the given schema name,
+// or "" if there is none.
+func (cr *controller) tenantStatus(ctx context.Context, schemaName string) (string, error) {
+	var statuses []string
+	if err := cr.db.WithContext(ctx).Model(&models.Tenant{}).
+		Where("schema_name = ?", schemaName).
+		Limit(1).
+		Pluck("status", &statuses).Error; err != nil {
+		return "", err
+	}
+	if len(statuses) == 0 {
+		return "", nil
+	}
+	return statuses[0], nil
+}
+
+// resolveTenant returns the status of the tenant of a request from the
+// registry, in a span of its own.
+func (cr *controller) resolveTenant(ctx context.Context, tenantID string) (string, error) {
+	ctx, span := cr.startSpan(ctx, "resolve tenant", tenantAttribute.String(tenantID))
+	status, err := cr.tenants.Status(ctx, tenantID)
+	endSpan(span, err)
+	return status, err
+}
+
+// verifyTenant returns a middleware rejecting requests whose resolved tenant
+// does not exist or is not active.
+func (cr *controller) verifyTenant() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenantID, err := TenantFromContext(c)
+			if err != nil {
+				return next(c) // no tenant resolved for this route
+			}
+			status, err := cr.resolveTenant(c.Request().Context(), tenantID)
+			if err != nil {
+				return err
+			}
+			if status == "" {
+				return echo.NewHTTPError(http.StatusNotFound, "tenant not found")
+			}
+			if err = inactiveTenantError(status); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// inactiveTenantError returns the error a request of a tenant with the given
+// status fails with, or nil if the tenant is active.
+func inactiveTenantError(status string) error {
+	switch status {
+	case models.TenantSuspended:
+		return echo.NewHTTPError(http.StatusForbidden, "tenant is suspended")
+	case models.TenantPending:
+		return echo.NewHTTPError(http.StatusLocked, "tenant is pending migration")
+	}
+	return nil
+}
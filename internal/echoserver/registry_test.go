@@ -0,0 +1,139 @@
+package echoserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticTenants returns a registry whose lookup only knows the given tenants.
+func staticTenants(names ...string) *TenantRegistry {
+	return NewTenantRegistry(time.Minute, func(_ context.Context, schemaName string) (bool, error) {
+		return slices.Contains(names, schemaName), nil
+	})
+}
+
+func TestTenantRegistry(t *testing.T) {
+	ctx := context.Background()
+	var lookups atomic.Int32
+	r := NewTenantRegistry(time.Minute, func(_ context.Context, schemaName string) (bool, error) {
+		lookups.Add(1)
+		return schemaName == "tenant1", nil
+	})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	exists, err := r.Exists(ctx, "tenant1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = r.Exists(ctx, "tenant1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.EqualValues(t, 1, lookups.Load(), "second lookup is served from the cache")
+
+	exists, err = r.Exists(ctx, "tenant2")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Size: 1}, r.Stats())
+
+	now = now.Add(2 * time.Minute)
+	_, err = r.Exists(ctx, "tenant1")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, lookups.Load(), "expired entries are looked up again")
+
+	r.Add("tenant3")
+	exists, err = r.Exists(ctx, "tenant3")
+	require.NoError(t, err)
+	assert.True(t, exists, "added tenants are known without a lookup")
+	r.Remove("tenant3")
+	exists, err = r.Exists(ctx, "tenant3")
+	require.NoError(t, err)
+	assert.False(t, exists, "removed tenants are looked up again")
+}
+
+func TestTenantRegistryConcurrency(t *testing.T) {
+	ctx := context.Background()
+	r := NewTenantRegistry(time.Minute, func(context.Context, string) (bool, error) {
+		return true, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				exists, err := r.Exists(ctx, fmt.Sprintf("tenant%d", j%10))
+				assert.NoError(t, err)
+				assert.True(t, exists)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := range 1000 {
+				name := fmt.Sprintf("tenant%d", (i+j)%10)
+				if j%2 == 0 {
+					r.Add(name)
+				} else {
+					r.Remove(name)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, r.Stats().Size, 10)
+}
+
+func TestVerifyTenant(t *testing.T) {
+	cr := &controller{tenants: staticTenants("tenant1")}
+	e := echo.New()
+	cr.init(e)
+	e.GET("/ping", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	for host, status := range map[string]int{
+		"tenant1.example.com": http.StatusOK,
+		"tenant9.example.com": http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code, host)
+	}
+}
+
+func TestVerifyTenantLookupError(t *testing.T) {
+	failing := NewTenantRegistry(time.Minute, func(context.Context, string) (bool, error) {
+		return false, fmt.Errorf("dial tcp 10.0.0.5:5432: connection refused")
+	})
+	key := []byte("jwt-secret")
+	for name, opts := range map[string]Options{
+		"Host": {},
+		"JWT":  {TenantStrategy: TenantFromJWT, JWTKey: key},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cr := &controller{opts: opts, tenants: failing}
+			e := echo.New()
+			cr.init(e)
+			e.GET("/ping", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			req.Host = "tenant1.example.com"
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+signTenantToken(t, key, "tenant1"))
+			rr := httptest.NewRecorder()
+			e.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusInternalServerError, rr.Code)
+			assert.JSONEq(t, `{"message": "internal server error", "category": "internal"}`, rr.Body.String())
+		})
+	}
+}
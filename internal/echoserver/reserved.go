@@ -0,0 +1,43 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// defaultReservedSchemas are the shared and system schemas of the supported
+// databases. They are always reserved.
+var defaultReservedSchemas = []string{
+	"public", "information_schema", "pg_catalog", "pg_toast", // PostgreSQL
+	"mysql", "sys", "performance_schema", // MySQL
+}
+
+var errReservedSchema = errors.New("schema is reserved")
+
+// isReservedSchema reports whether name may not be used as a tenant schema.
+func (cr *controller) isReservedSchema(name string) bool {
+	match := func(reserved string) bool { return strings.EqualFold(reserved, name) }
+	return slices.ContainsFunc(defaultReservedSchemas, match) ||
+		slices.ContainsFunc(cr.opts.ReservedSchemas, match)
+}
+
+// offboardTenant drops the schema of a tenant. It refuses to drop reserved
+// schemas, whatever the tenant record says, since that would destroy data
+// shared by every tenant.
+func (cr *controller) offboardTenant(ctx context.Context, schemaName string) error {
+	if cr.isReservedSchema(schemaName) {
+		return fmt.Errorf("refusing to offboard %q: %w", schemaName, errReservedSchema)
+	}
+	m, err := cr.migrator(ctx, schemaName)
+	if err != nil {
+		return err
+	}
+	if err = m.OffboardTenant(ctx, schemaName); err != nil {
+		return err
+	}
+	cr.resetStatements(schemaName)
+	return nil
+}
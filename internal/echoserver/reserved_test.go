@@ -0,0 +1,49 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMigrator records the schemas it is asked to offboard.
+type recordingMigrator struct {
+	tenantMigrator
+	offboarded []string
+}
+
+func (m *recordingMigrator) OffboardTenant(ctx context.Context, tenantID string) error {
+	m.offboarded = append(m.offboarded, tenantID)
+	return m.tenantMigrator.OffboardTenant(ctx, tenantID)
+}
+
+func TestOffboardReservedSchema(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	m := &recordingMigrator{tenantMigrator: cr.db}
+	cr.migrations = m
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// Simulate a corrupted tenant record pointing at the shared schema.
+	require.NoError(t, cr.db.Model(&models.Tenant{}).Where("id = ?", 1).Update("schema_name", "PUBLIC").Error)
+
+	rr = serve(e, http.MethodDelete, "/tenants/1?confirm=true", "", "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.JSONEq(t, `{"message": "refusing to offboard \"PUBLIC\": schema is reserved"}`, rr.Body.String())
+	assert.Empty(t, m.offboarded, "reserved schema is never offboarded")
+
+	rr = serve(e, http.MethodGet, "/tenants/1", "", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "shared schema and tenant record are intact: %s", rr.Body.String())
+}
+
+func TestOnboardReservedSchema(t *testing.T) {
+	_, e := newSQLiteServer(t, WithReservedSchemas("billing"))
+	for _, domain := range []string{"public.example.com", "pg_catalog.example.com", "billing.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+domain+`"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, domain)
+	}
+}
@@ -0,0 +1,86 @@
+package echoserver
+
+import (
+	"context"
+
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+)
+
+// TenantResolver returns the database holding a tenant's schema. The tenant
+// records themselves always live in the server's shared database.
+type TenantResolver interface {
+	Resolve(ctx context.Context, tenantID string) (*multitenancy.DB, error)
+}
+
+// SchemaResolver serves every tenant from its schema in one shared database.
+// It is the default resolver.
+type SchemaResolver struct {
+	DB *multitenancy.DB
+}
+
+var _ TenantResolver = SchemaResolver{}
+
+// Resolve implements [TenantResolver].
+func (r SchemaResolver) Resolve(context.Context, string) (*multitenancy.DB, error) {
+	return r.DB, nil
+}
+
+// DedicatedResolver serves the tenants in Dedicated from their own database,
+// isolated from every other tenant, and the rest from Shared. A dedicated
+// database holds the tenant's schema and its book counter, so its models must
+// be registered like those of the shared database.
+type DedicatedResolver struct {
+	Shared    TenantResolver
+	Dedicated map[string]*multitenancy.DB
+}
+
+var _ TenantResolver = DedicatedResolver{}
+
+// Resolve implements [TenantResolver].
+func (r DedicatedResolver) Resolve(ctx context.Context, tenantID string) (*multitenancy.DB, error) {
+	if db, ok := r.Dedicated[tenantID]; ok {
+		return db, nil
+	}
+	return r.Shared.Resolve(ctx, tenantID)
+}
+
+// resolver returns the tenant resolver, defaulting to the shared database.
+func (cr *controller) resolver() TenantResolver {
+	if cr.opts.Resolver != nil {
+		return cr.opts.Resolver
+	}
+	return SchemaResolver{DB: cr.db}
+}
+
+// tenantDB returns the database holding the tenant's schema, bound to ctx.
+// With [Options.PrepareStatements] its statements are prepared and cached
+// for the tenant's schema.
+func (cr *controller) tenantDB(ctx context.Context, tenantID string) (*multitenancy.DB, error) {
+	db, err := cr.schemaDB(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if cr.stmts != nil {
+		db.Statement.ConnPool = cr.stmts.get(db.Statement.ConnPool, tenantID)
+	}
+	return db, nil
+}
+
+// schemaDB returns the database holding the tenant's schema, bound to ctx,
+// without preparing its statements.
+func (cr *controller) schemaDB(ctx context.Context, tenantID string) (*multitenancy.DB, error) {
+	db, err := cr.resolver().Resolve(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return db.WithContext(ctx), nil
+}
+
+// isShared reports whether the tenant's schema lives in the shared database.
+func (cr *controller) isShared(ctx context.Context, tenantID string) (bool, error) {
+	db, err := cr.resolver().Resolve(ctx, tenantID)
+	if err != nil {
+		return false, err
+	}
+	return db.ConnPool == cr.db.ConnPool, nil
+}
@@ -0,0 +1,53 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedicatedResolver(t *testing.T) {
+	shared, dedicated := newSQLiteDB(t), newSQLiteDB(t)
+	const token = "admin-secret"
+	_, e := newServer(shared, WithAdminToken(token), WithTenantResolver(DedicatedResolver{
+		Shared:    SchemaResolver{DB: shared},
+		Dedicated: map[string]*multitenancy.DB{"big": dedicated},
+	}))
+	for _, domain := range []string{"big.example.com", "small.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+domain+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodPost, "/books", domain, `{"name": "Dune", "author": "Frank Herbert"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	ctx := context.Background()
+	schemas := func(db *multitenancy.DB) []string {
+		t.Helper()
+		s, err := (&controller{db: db}).tenantSchemas(ctx)
+		require.NoError(t, err)
+		return s
+	}
+	assert.Equal(t, []string{"big"}, schemas(dedicated), "dedicated tenant lives in its own database")
+	assert.Equal(t, []string{"small"}, schemas(shared))
+
+	rr := serve(e, http.MethodGet, "/books", "big.example.com", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Len(t, decode[[]models.BookResponse](t, rr), 1)
+	rr = serve(e, http.MethodGet, "/books/count", "big.example.com", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, int64(1), decode[models.BookCountResponse](t, rr).Count)
+
+	rr = serveAdmin(e, http.MethodPost, "/admin/reconcile", token)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Empty(t, decode[models.ReconcileResponse](t, rr).MissingSchemas, "dedicated tenant is not reported missing")
+
+	rr = serve(e, http.MethodDelete, "/tenants/1?confirm=true", "", "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Empty(t, schemas(dedicated), "dedicated tenant schema is dropped")
+	assert.Equal(t, []string{"small"}, schemas(shared))
+}
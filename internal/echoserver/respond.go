@@ -0,0 +1,72 @@
+package echoserver
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MIMEApplicationMsgpack is the media type of MessagePack responses.
+const MIMEApplicationMsgpack = "application/msgpack"
+
+// HeaderXAffectedRows reports how many rows an update or delete changed.
+const HeaderXAffectedRows = "X-Affected-Rows"
+
+// setAffectedRows reports n changed rows, so clients can tell whether an
+// update or delete matched anything.
+func setAffectedRows(c echo.Context, n int64) {
+	c.Response().Header().Set(HeaderXAffectedRows, strconv.FormatInt(n, 10))
+}
+
+// respond writes v with the given status, encoded as MessagePack if the
+// client prefers it and as JSON otherwise.
+func respond(c echo.Context, status int, v any) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if !prefersMsgpack(c.Request().Header.Get(echo.HeaderAccept)) {
+		return c.JSON(status, v)
+	}
+	b, err := marshalMsgpack(v)
+	if err != nil {
+		return err
+	}
+	return c.Blob(status, MIMEApplicationMsgpack, b)
+}
+
+// marshalMsgpack encodes v as MessagePack, using the JSON field names so
+// both encodings share one schema.
+func marshalMsgpack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// prefersMsgpack reports whether the Accept header ranks MessagePack above
+// JSON. Ties, including a missing header, go to JSON.
+func prefersMsgpack(accept string) bool {
+	var msgpackQ, jsonQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && k == "q" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case MIMEApplicationMsgpack, "application/x-msgpack":
+			msgpackQ = max(msgpackQ, q)
+		case echo.MIMEApplicationJSON, "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return msgpackQ > jsonQ
+}
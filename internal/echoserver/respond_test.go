@@ -0,0 +1,117 @@
+package echoserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPrefersMsgpack(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                                      false,
+		"*/*":                                   false,
+		"application/json":                      false,
+		"application/msgpack":                   true,
+		"application/x-msgpack":                 true,
+		"application/msgpack, */*;q=0.1":        true,
+		"application/json, application/msgpack": false,
+		"application/json;q=0.5, application/msgpack;q=0.8": true,
+		"application/msgpack;q=0":                           false,
+	} {
+		assert.Equal(t, want, prefersMsgpack(accept), accept)
+	}
+}
+
+func unmarshalMsgpack(t *testing.T, b []byte, v any) {
+	t.Helper()
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetCustomStructTag("json")
+	require.NoError(t, dec.Decode(v))
+}
+
+func TestRespondContentNegotiation(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	request := func(method, path, body, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAccept, accept)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+	want := models.BookResponse{ID: 1, Name: "Book 1", Author: "Author 1", ISBN: "9780306406157", Version: 1}
+
+	rr = request(http.MethodPost, "/books", `{"name": "Book 1", "author": "Author 1", "isbn": "9780306406157"}`, MIMEApplicationMsgpack)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, MIMEApplicationMsgpack, rr.Header().Get(echo.HeaderContentType))
+	var got models.BookResponse
+	unmarshalMsgpack(t, rr.Body.Bytes(), &got)
+	assert.Equal(t, want, got)
+
+	rr = request(http.MethodGet, "/books", "", echo.MIMEApplicationJSON)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+	var books []models.BookResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &books))
+	assert.Equal(t, []models.BookResponse{want}, books)
+
+	rr = request(http.MethodGet, "/books", "", MIMEApplicationMsgpack)
+	require.Equal(t, http.StatusOK, rr.Code)
+	books = nil
+	unmarshalMsgpack(t, rr.Body.Bytes(), &books)
+	assert.Equal(t, []models.BookResponse{want}, books)
+
+	t.Run("Errors", func(t *testing.T) {
+		rr := request(http.MethodPost, "/books", `{"name": "Book 2"}`, MIMEApplicationMsgpack)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, MIMEApplicationMsgpack, rr.Header().Get(echo.HeaderContentType))
+		var res models.ErrorResponse
+		unmarshalMsgpack(t, rr.Body.Bytes(), &res)
+		assert.Equal(t, models.ErrorResponse{
+			Message:  "request validation failed",
+			Category: string(CategoryValidation),
+			Fields:   []models.FieldError{{Field: "author", Rule: "required"}},
+		}, res)
+	})
+}
+
+func TestAffectedRows(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	for _, name := range []string{"Dune", "Emma", "Ulysses"} {
+		rr = serve(e, http.MethodPost, "/books", host, `{"name": "`+name+`", "author": "Author"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               string
+	}{
+		{http.MethodPut, "/books/1", `{"name": "Dune Messiah", "version": 1}`, "1"},
+		{http.MethodPut, "/books/9", `{"name": "Missing", "version": 1}`, "0"},
+		{http.MethodDelete, "/books/1", "", "1"},
+		{http.MethodDelete, "/books", `{"ids": [1, 2, 3, 9]}`, "2"},
+		{http.MethodDelete, "/books", `{"ids": [2, 3]}`, "0"},
+	}
+	for _, tt := range tests {
+		rr = serve(e, tt.method, tt.path, host, tt.body)
+		require.Less(t, rr.Code, 300, rr.Body.String())
+		assert.Equal(t, tt.want, rr.Header().Get(HeaderXAffectedRows), "%s %s %s", tt.method, tt.path, tt.body)
+	}
+}
@@ -0,0 +1,75 @@
+package echoserver
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"gorm.io/gorm"
+)
+
+// DeletedBookPolicy decides what creating a book does when the tenant has a
+// soft-deleted book with the same ISBN.
+type DeletedBookPolicy int
+
+const (
+	// CreateNewBook creates a new book, leaving the deleted one deleted. This
+	// is the default.
+	CreateNewBook DeletedBookPolicy = iota
+	// RestoreDeletedBook restores the most recently deleted book with the
+	// ISBN, updated with the new name, author and tags, rather than
+	// duplicating it.
+	RestoreDeletedBook
+)
+
+// restoreDeletedBook restores the tenant's soft-deleted book with the ISBN
+// of book, if [Options.DeletedBookPolicy] asks for it, updating it from book.
+// The tags of book replace those of the deleted book. It reports whether a
+// book was restored; if so, book is filled in from it.
+// It must run in the tenant's transaction.
+func (cr *controller) restoreDeletedBook(tx *multitenancy.DB, tenantID string, book *models.Book) (bool, error) {
+	if cr.opts.DeletedBookPolicy != RestoreDeletedBook || book.ISBN == "" {
+		return false, nil
+	}
+	var deleted models.Book
+	err := tx.Unscoped().
+		Where("tenant_schema = ? AND isbn = ? AND deleted_at IS NOT NULL", tenantID, book.ISBN).
+		Order("deleted_at DESC").
+		First(&deleted).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// The restore changes the book, so it must change its ETag and the
+	// tenant's Last-Modified too: updated_at is set explicitly rather than
+	// left to GORM's auto timestamp, which the Select may restrict.
+	now := time.Now()
+	if err = tx.Unscoped().Model(&deleted).Select("deleted_at", "name", "author", "version", "updated_at").Updates(map[string]any{
+		"deleted_at": nil,
+		"name":       book.Name,
+		"author":     book.Author,
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": now,
+	}).Error; err != nil {
+		return false, err
+	}
+	if err = tx.Where("book_id = ?", deleted.ID).Delete(&models.Tag{}).Error; err != nil {
+		return false, err
+	}
+	for i := range book.Tags {
+		book.Tags[i].BookID = deleted.ID
+	}
+	if len(book.Tags) > 0 {
+		if err = tx.Create(&book.Tags).Error; err != nil {
+			return false, err
+		}
+	}
+	deleted.UpdatedAt = now
+	book.Model = deleted.Model
+	book.Version = deleted.Version + 1
+	book.DeletedAt = gorm.DeletedAt{}
+	return true, nil
+}
@@ -0,0 +1,109 @@
+package echoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletedBookPolicy(t *testing.T) {
+	const (
+		host1, host2 = "tenant1.example.com", "tenant2.example.com"
+		book         = `{"name": "Book", "author": "Author", "isbn": "978-0-306-40615-7"}`
+		again        = `{"name": "Book, 2nd edition", "author": "Author", "isbn": "9780306406157"}`
+	)
+	setup := func(t *testing.T, policy DeletedBookPolicy) func(method, path, host, body string) *models.BookResponse {
+		_, e := newSQLiteServer(t, WithDeletedBookPolicy(policy))
+		send := func(method, path, host, body string) *models.BookResponse {
+			t.Helper()
+			rr := serve(e, method, path, host, body)
+			if rr.Code == http.StatusNotFound {
+				return nil
+			}
+			require.Less(t, rr.Code, 300, rr.Body.String())
+			if rr.Code == http.StatusNoContent {
+				return nil
+			}
+			res := decode[models.BookResponse](t, rr)
+			return &res
+		}
+		for _, host := range []string{host1, host2} {
+			serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+			require.Equal(t, uint(1), send(http.MethodPost, "/books", host, book).ID)
+			send(http.MethodDelete, "/books/1", host, "")
+		}
+		return send
+	}
+
+	t.Run("RestoreDeletedBook", func(t *testing.T) {
+		send := setup(t, RestoreDeletedBook)
+		res := send(http.MethodPost, "/books", host1, again)
+		assert.Equal(t, &models.BookResponse{ID: 1, Name: "Book, 2nd edition", Author: "Author", ISBN: "9780306406157", Version: 2}, res,
+			"the deleted book is restored and updated")
+		assert.Equal(t, res, send(http.MethodGet, "/books/1", host1, ""))
+		assert.Nil(t, send(http.MethodGet, "/books/1", host2, ""), "the other tenant's book stays deleted")
+
+		res = send(http.MethodPost, "/books", host1, again)
+		assert.Equal(t, uint(2), res.ID, "a live book is not restored again")
+	})
+	t.Run("RestoreWithTags", func(t *testing.T) {
+		_, e := newSQLiteServer(t, WithDeletedBookPolicy(RestoreDeletedBook))
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host1+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodPost, "/books", host1, `{"name": "Book", "author": "Author", "isbn": "9780306406157", "tags": [{"name": "old"}]}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodDelete, "/books/1", host1, "")
+		require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodPost, "/books", host1, `{"name": "Book", "author": "Author", "isbn": "9780306406157", "tags": [{"name": "go"}, {"name": "new"}]}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		require.Equal(t, uint(1), decode[models.BookResponse](t, rr).ID)
+
+		rr = serve(e, http.MethodGet, "/books?view=flat", host1, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, []models.FlatBookResponse{
+			{BookID: 1, Name: "Book", Author: "Author", ISBN: "9780306406157", Tag: "go"},
+			{BookID: 1, Name: "Book", Author: "Author", ISBN: "9780306406157", Tag: "new"},
+		}, decode[[]models.FlatBookResponse](t, rr), "the new tags replace the deleted book's")
+	})
+	t.Run("ConditionalGet", func(t *testing.T) {
+		_, e := newSQLiteServer(t, WithDeletedBookPolicy(RestoreDeletedBook))
+		get := func(header, value string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+			req.Header.Set(header, value)
+			req.Host = host1
+			rr := httptest.NewRecorder()
+			e.ServeHTTP(rr, req)
+			return rr
+		}
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host1+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodPost, "/books", host1, book)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		rr = get("If-None-Match", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		etag := rr.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		time.Sleep(5 * time.Millisecond)
+		rr = serve(e, http.MethodDelete, "/books/1", host1, "")
+		require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodPost, "/books", host1, again)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+		rr = get("If-None-Match", etag)
+		require.Equal(t, http.StatusOK, rr.Code, "the restored book is served again")
+		assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+		assert.Equal(t, "Book, 2nd edition", decode[models.BookResponse](t, rr).Name)
+	})
+	t.Run("CreateNewBook", func(t *testing.T) {
+		send := setup(t, CreateNewBook)
+		res := send(http.MethodPost, "/books", host1, again)
+		assert.Equal(t, uint(2), res.ID, "a new book is created")
+		assert.Nil(t, send(http.MethodGet, "/books/1", host1, ""), "the deleted book stays deleted")
+	})
+}
@@ -0,0 +1,93 @@
+package echoserver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	defaultMigrationAttempts  = 3
+	defaultMigrationBaseDelay = 100 * time.Millisecond
+	maxRetryDelay             = 5 * time.Second
+)
+
+// transientPgCodes are PostgreSQL error codes, besides the retryable
+// category, of failures expected to clear up on their own.
+var transientPgCodes = map[string]bool{
+	"53300": true, // too_many_connections
+	"57P03": true, // cannot_connect_now
+}
+
+// transientMySQLNumbers are MySQL error numbers, besides the retryable
+// category, of failures expected to clear up on their own.
+var transientMySQLNumbers = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR
+}
+
+// isTransient reports whether err is a failure worth retrying: a
+// serialization failure or deadlock, an overloaded server, or a lost
+// connection. Deterministic errors, such as an invalid schema name, are not.
+func isTransient(err error) bool {
+	if classifyError(err) == CategoryRetryable || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return transientPgCodes[pgErr.Code] || strings.HasPrefix(pgErr.Code, "08") // connection_exception
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return transientMySQLNumbers[mysqlErr.Number]
+	}
+	return false
+}
+
+// retry calls fn up to attempts times while it fails with a transient error,
+// sleeping between attempts for an exponentially growing delay starting at
+// baseDelay, with full jitter. It gives up early once ctx is done.
+func retry(ctx context.Context, attempts int, baseDelay time.Duration, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := range max(attempts, 1) {
+		if attempt > 0 {
+			delay := min(baseDelay<<(attempt-1), maxRetryDelay)
+			timer := time.NewTimer(rand.N(delay + 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+		if err = fn(ctx); err == nil || !isTransient(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// migrateTenantWithRetry migrates the tenant's schema, retrying transient
+// failures as configured by [Options.MigrationAttempts].
+func (cr *controller) migrateTenantWithRetry(ctx context.Context, tenantID string) error {
+	attempts := cr.opts.MigrationAttempts
+	if attempts <= 0 {
+		attempts = defaultMigrationAttempts
+	}
+	baseDelay := cr.opts.MigrationRetryDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultMigrationBaseDelay
+	}
+	attempt := 0
+	return retry(ctx, attempts, baseDelay, func(ctx context.Context) error {
+		if attempt++; attempt > 1 {
+			logJobStep(ctx, "retrying schema migration, attempt %d of %d", attempt, attempts)
+		}
+		return cr.migrateTenant(ctx, tenantID)
+	})
+}
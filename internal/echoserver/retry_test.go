@@ -0,0 +1,92 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyMigrator fails the first failures migrations with err.
+type flakyMigrator struct {
+	tenantMigrator
+	failures int32
+	err      error
+	calls    atomic.Int32
+}
+
+func (m *flakyMigrator) MigrateTenantModels(ctx context.Context, tenantID string) error {
+	if m.calls.Add(1) <= m.failures {
+		return m.err
+	}
+	return m.tenantMigrator.MigrateTenantModels(ctx, tenantID)
+}
+
+func TestOnboardingRetry(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: "40P01"}
+	tests := []struct {
+		name      string
+		failures  int32
+		err       error
+		wantCode  int
+		wantCalls int32
+	}{
+		{"recovers from transient failures", 2, deadlock, http.StatusCreated, 3},
+		{"gives up after max attempts", 5, deadlock, http.StatusServiceUnavailable, 3},
+		{"deterministic error is not retried", 5, &pgconn.PgError{Code: "3F000"}, http.StatusNotFound, 1},
+		{"unclassified error is not retried", 5, errors.New("syntax error"), http.StatusInternalServerError, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr, e := newSQLiteServer(t, WithMigrationRetry(3, time.Millisecond))
+			m := &flakyMigrator{tenantMigrator: cr.db, failures: tt.failures, err: tt.err}
+			cr.migrations = m
+
+			rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+			assert.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+			assert.Equal(t, tt.wantCalls, m.calls.Load())
+		})
+	}
+}
+
+func TestRetryRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int
+	start := time.Now()
+	err := retry(ctx, 10, time.Hour, func(context.Context) error {
+		calls++
+		cancel() // e.g. the client went away during the attempt
+		return &pgconn.PgError{Code: "40001"}
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls, "no attempt is made once ctx is done")
+	assert.Less(t, time.Since(start), 5*time.Second, "backoff is interrupted")
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, err := range []error{
+		&pgconn.PgError{Code: "40001"},
+		&pgconn.PgError{Code: "08006"},
+		&pgconn.PgError{Code: "53300"},
+		&mysql.MySQLError{Number: 1213},
+		&mysql.MySQLError{Number: 1040},
+	} {
+		require.True(t, isTransient(err), err.Error())
+	}
+	for _, err := range []error{
+		&pgconn.PgError{Code: "3F000"},
+		&pgconn.PgError{Code: "42601"},
+		&mysql.MySQLError{Number: 1049},
+		context.Canceled,
+	} {
+		require.False(t, isTransient(err), err.Error())
+	}
+}
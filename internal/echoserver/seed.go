@@ -0,0 +1,37 @@
+package echoserver
+
+import (
+	"context"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+)
+
+// seedBatchSize is the number of books inserted per statement when seeding.
+const seedBatchSize = 100
+
+// SeedTenant inserts books into the schema of an onboarded tenant, in a
+// single transaction so that either all or none of them are created.
+func SeedTenant(ctx context.Context, db *multitenancy.DB, schemaName string, books []models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	for i := range books {
+		books[i].TenantSchema = schemaName
+	}
+	return withTenantTx(ctx, db, schemaName, func(tx *multitenancy.DB) error {
+		if err := tx.CreateInBatches(books, seedBatchSize).Error; err != nil {
+			return err
+		}
+		return adjustBookCount(tx.DB, schemaName, int64(len(books)))
+	})
+}
+
+// sampleBooks returns the demo books seeded by "POST /tenants?seed=true".
+func sampleBooks() []models.Book {
+	return []models.Book{
+		{Name: "The Go Programming Language", Author: "Alan A. A. Donovan", ISBN: "9780134190440"},
+		{Name: "Designing Data-Intensive Applications", Author: "Martin Kleppmann", ISBN: "9781449373320"},
+		{Name: "Database Internals", Author: "Alex Petrov", ISBN: "9781492040347"},
+	}
+}
@@ -0,0 +1,59 @@
+package echoserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedTenant(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host1, host2 = "tenant1.example.com", "tenant2.example.com"
+	rr := serve(e, http.MethodPost, "/tenants?seed=true", "", `{"domainUrl": "`+host1+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host2+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = serve(e, http.MethodGet, "/books", host1, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	books := decode[[]models.BookResponse](t, rr)
+	require.Len(t, books, len(sampleBooks()))
+	for i, book := range sampleBooks() {
+		assert.Equal(t, book.Name, books[i].Name)
+		_, err := models.NormalizeISBN(book.ISBN)
+		assert.NoError(t, err, "sample ISBNs are valid")
+	}
+	rr = serve(e, http.MethodGet, "/books/count", host1, "")
+	assert.JSONEq(t, fmt.Sprintf(`{"count": %d}`, len(sampleBooks())), rr.Body.String())
+
+	rr = serve(e, http.MethodGet, "/books", host2, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Empty(t, decode[[]models.BookResponse](t, rr), "seeded books are only visible in the seeded tenant")
+
+	rr = serve(e, http.MethodPost, "/tenants?seed=maybe", "", `{"domainUrl": "tenant3.example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSeedTenantRollsBack(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// The last batch conflicts with the first, after it was inserted.
+	books := make([]models.Book, seedBatchSize+1)
+	for i := range books {
+		books[i] = models.Book{Name: fmt.Sprintf("Book %d", i), Author: "Author"}
+	}
+	books[0].ID, books[seedBatchSize].ID = 1, 1
+	require.Error(t, SeedTenant(context.Background(), cr.db, "tenant1", books))
+
+	rr = serve(e, http.MethodGet, "/books", host, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Empty(t, decode[[]models.BookResponse](t, rr), "a failed seed leaves no books")
+}
@@ -0,0 +1,82 @@
+package echoserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/scopes"
+)
+
+// selfTest onboards a throwaway tenant, creates and reads back one of its
+// books, then offboards it, exercising the multi-tenant path end to end
+// before the server accepts traffic. The tenant is removed whichever step
+// fails.
+func (cr *controller) selfTest(ctx context.Context) (err error) {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	schema := "selftest_" + hex.EncodeToString(suffix)
+	tenant := &models.Tenant{
+		TenantModel: multitenancy.TenantModel{
+			DomainURL:  schema + ".selftest.invalid",
+			SchemaName: schema,
+		},
+		Status: models.TenantActive,
+	}
+	if err = cr.db.WithContext(ctx).Create(tenant).Error; err != nil {
+		return fmt.Errorf("creating tenant %q: %w", schema, err)
+	}
+	defer func() {
+		if cleanupErr := cr.removeSelfTestTenant(context.WithoutCancel(ctx), tenant); cleanupErr != nil {
+			err = errors.Join(err, fmt.Errorf("removing tenant %q: %w", schema, cleanupErr))
+		}
+	}()
+
+	if err = cr.onboardTenant(ctx, tenant, nil); err != nil {
+		return fmt.Errorf("onboarding tenant %q: %w", schema, err)
+	}
+	book := models.Book{Name: "Self-test", Author: "echoserver", TenantSchema: schema}
+	if err = cr.withTenantTx(ctx, schema, func(tx *multitenancy.DB) error {
+		if err := tx.Create(&book).Error; err != nil {
+			return err
+		}
+		return adjustBookCount(tx.DB, schema, 1)
+	}); err != nil {
+		return fmt.Errorf("creating a book of tenant %q: %w", schema, err)
+	}
+	db, err := cr.tenantDB(ctx, schema)
+	if err != nil {
+		return fmt.Errorf("reading a book of tenant %q: %w", schema, err)
+	}
+	var read models.Book
+	if err = db.Scopes(scopes.WithTenantSchema(schema)).First(&read, book.ID).Error; err != nil {
+		return fmt.Errorf("reading a book of tenant %q: %w", schema, err)
+	}
+	if read.Name != book.Name {
+		return fmt.Errorf("reading a book of tenant %q: got %q, want %q", schema, read.Name, book.Name)
+	}
+	log.Printf("Self-test passed with tenant %q", schema)
+	return nil
+}
+
+// removeSelfTestTenant drops the self-test tenant's schema, counter and row.
+// Unlike an offboarded tenant's, the row is not kept, so the throwaway
+// schema name is not left taken.
+func (cr *controller) removeSelfTestTenant(ctx context.Context, tenant *models.Tenant) error {
+	err := cr.offboardTenant(ctx, tenant.SchemaName)
+	cr.tenants.Remove(tenant.SchemaName)
+	db, dbErr := cr.tenantDB(ctx, tenant.SchemaName)
+	if dbErr == nil {
+		dbErr = db.Delete(&models.BookCounter{}, "tenant_schema = ?", tenant.SchemaName).Error
+	}
+	err = errors.Join(err, dbErr)
+	if delErr := cr.db.WithContext(ctx).Unscoped().Delete(tenant).Error; delErr != nil {
+		err = errors.Join(err, delErr)
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// tenantRows counts the tenant rows, deleted ones included.
+func tenantRows(t *testing.T, srv *Server) int64 {
+	t.Helper()
+	var n int64
+	require.NoError(t, srv.cr.db.Unscoped().Model(&models.Tenant{}).Count(&n).Error)
+	return n
+}
+
+func TestSelfTest(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	t.Run("Healthy", func(t *testing.T) {
+		srv := NewServer(newSQLiteDB(t), WithAddr(addr), WithSelfTest(true))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- srv.Start(ctx) }()
+
+		require.Eventually(t, func() bool {
+			res, err := http.Get("http://" + addr + healthPath)
+			if err != nil {
+				return false
+			}
+			res.Body.Close()
+			return res.StatusCode == http.StatusOK
+		}, 5*time.Second, 10*time.Millisecond, "the server starts once the self-test passed")
+		assert.Zero(t, tenantRows(t, srv), "the throwaway tenant is removed")
+
+		cancel()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("the server did not shut down")
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		srv := NewServer(newSQLiteDB(t), WithAddr(addr), WithSelfTest(true))
+		// The tenant is onboarded, but its book cannot be created.
+		var schema string
+		require.NoError(t, srv.cr.db.Callback().Create().Before("gorm:create").Register("test:fail", func(db *gorm.DB) {
+			if book, ok := db.Statement.Dest.(*models.Book); ok {
+				schema = book.TenantSchema
+				_ = db.AddError(errors.New("disk full"))
+			}
+		}))
+
+		err := srv.Start(context.Background())
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "startup self-test")
+		assert.ErrorContains(t, err, "disk full")
+		require.NotEmpty(t, schema)
+		assert.Zero(t, tenantRows(t, srv), "the throwaway tenant is removed")
+		_, err = srv.cr.db.UseTenant(context.Background(), schema)
+		assert.Error(t, err, "the throwaway schema is dropped")
+
+		_, err = http.Get("http://" + addr + healthPath)
+		assert.Error(t, err, "no traffic is accepted")
+	})
+}
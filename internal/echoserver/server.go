@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,66 +20,374 @@ import (
 )
 
 type controller struct {
-	db   *multitenancy.DB
-	once sync.Once
+	db          *multitenancy.DB
+	migrations  tenantMigrator // migrations overrides db for schema changes in tests.
+	opts        Options
+	caches      map[string]Cache
+	tenants     *TenantRegistry
+	limits      *tenantLimiter
+	jobs        *jobStore
+	streams     *streamTracker
+	nonces      *nonceStore
+	idempotency *idempotencyStore
+	stmts       *stmtCaches        // stmts caches the prepared statements of each tenant schema, if enabled.
+	workers     sync.WaitGroup     // workers tracks the background workers.
+	stopWorkers context.CancelFunc // stopWorkers stops the background workers.
+	reconciling sync.Mutex         // reconciling is held while drift is being fixed, so fixes never overlap.
+
+	mu    sync.Mutex  // mu guards state.
+	state serverState // state reports whether the server is running.
 }
 
+// serverState is the lifecycle state of a controller's server.
+type serverState int
+
+const (
+	stateIdle serverState = iota
+	stateRunning
+)
+
+// ErrAlreadyRunning is returned when starting a [Server] that is running.
+var ErrAlreadyRunning = errors.New("server is already running")
+
+const (
+	defaultAddr         = ":8080"
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+)
+
 func (c *controller) init(e *echo.Echo) {
+	if c.tenants == nil {
+		ttl := c.opts.TenantCacheTTL
+		if ttl <= 0 {
+			ttl = defaultTenantCacheTTL
+		}
+		c.tenants = NewTenantStatusRegistry(ttl, c.tenantStatus)
+	}
+	c.registerCache("tenants", c.tenants)
+	if c.jobs == nil {
+		c.jobs = newJobStore()
+	}
+	if c.streams == nil {
+		c.streams = newStreamTracker()
+	}
+	if c.nonces == nil {
+		c.nonces = newNonceStore()
+	}
+	if c.idempotency == nil {
+		c.idempotency = newIdempotencyStore()
+	}
+	c.registerCache("idempotency", c.idempotency)
+
+	e.HTTPErrorHandler = c.httpErrorHandler
+	e.Validator = newRequestValidator()
+	if c.db != nil && len(c.opts.TenantLogSinks) > 0 {
+		c.useTenantLogSinks()
+	}
+	if c.db != nil && c.opts.TracerProvider != nil {
+		if err := EnableTracing(c.db.DB); err != nil {
+			log.Printf("Failed to enable tracing: %v", err)
+		}
+	}
+	if c.db != nil && c.opts.SQLComments {
+		if err := EnableSQLComments(c.db.DB); err != nil {
+			log.Printf("Failed to enable SQL comments: %v", err)
+		}
+	}
+	switch {
+	case !c.opts.PrepareStatements:
+	case c.opts.SQLComments:
+		// Every commented statement is unique, so none would be reused.
+		log.Printf("Not caching prepared statements: SQL comments make every statement unique")
+	case c.stmts == nil:
+		c.stmts = newStmtCaches()
+		c.registerCache("statements", c.stmts)
+	}
+	if c.opts.RateLimit > 0 {
+		c.limits = newTenantLimiter(c.opts.RateLimit, c.opts.RateBurst)
+	}
+
+	e.Use(requestID())
 	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(echomw.WithTenant(echomw.WithTenantConfig{
-		Skipper: func(c echo.Context) bool {
-			return strings.HasPrefix(c.Request().URL.Path, "/tenants") // skip tenant routes
-		},
-	}))
+	if c.opts.TracerProvider != nil {
+		e.Use(c.traceRequests())
+	}
+	e.Use(c.recoverJSON())
+	if c.opts.MaxInFlight > 0 {
+		e.Use(c.limitInFlight(c.opts.MaxInFlight))
+	}
+	if c.opts.MaxBodyBytes > 0 || c.opts.BodyBudget > 0 {
+		e.Use(c.limitBodies())
+	}
+	e.Use(validateHost())
+	switch c.opts.TenantStrategy {
+	case TenantFromJWT:
+		e.Use(c.withJWTTenant())
+	default:
+		e.Use(echomw.WithTenant(echomw.WithTenantConfig{
+			Skipper: func(ec echo.Context) bool {
+				return c.skipTenant(ec.Request().URL.Path)
+			},
+		}))
+		e.Use(c.verifyTenant())
+	}
+	e.Use(tenantContext())
+
+	// The middleware above is global, so that it also covers requests for
+	// unknown paths; the routes are mounted under the base path.
+	g := e.Group(c.basePath())
+	var gzip echo.MiddlewareFunc
+	if c.opts.Compression {
+		gzip = c.compress()
+	}
+	for _, r := range c.routes() {
+		var mw []echo.MiddlewareFunc
+		if gzip != nil && !r.stream {
+			mw = append(mw, gzip)
+		}
+		if c.opts.BodyLogging && !r.stream {
+			mw = append(mw, c.logBodies())
+		}
+		if c.opts.HandlerTimeout > 0 && !r.stream {
+			mw = append(mw, timeout(c.opts.HandlerTimeout))
+		}
+		if r.admin {
+			mw = append(mw, c.requireAdmin())
+		}
+		if r.destructive && len(c.opts.SigningKey) > 0 {
+			mw = append(mw, c.requireSignature())
+		}
+		if r.tenant && c.limits != nil {
+			mw = append(mw, c.rateLimit(r.cost))
+		}
+		if r.idempotent {
+			mw = append(mw, c.idempotent())
+		}
+		g.Add(r.method, r.path, r.handler, mw...)
+	}
+
+	g.GET("/openapi.json", c.openAPIHandler)
+	g.GET("/docs", swaggerUIHandler)
+}
+
+// route describes an API route along with the metadata used to document it
+// in the OpenAPI spec.
+type route struct {
+	method      string
+	path        string
+	handler     echo.HandlerFunc
+	summary     string
+	status      int    // status is the success status code.
+	request     any    // request is a sample of the request body, if any.
+	response    any    // response is a sample of the response body, if any.
+	tenant      bool   // tenant reports whether the route is served for a resolved tenant.
+	admin       bool   // admin reports whether the route requires the admin token.
+	cost        int    // cost is the rate limit budget consumed per request; zero means 1.
+	stream      bool   // stream reports whether the response is streamed, so it must not be compressed.
+	mediaType   string // mediaType is the media type of a streamed response.
+	destructive bool   // destructive reports whether the route destroys data, so it must be signed if signing is enabled.
+	idempotent  bool   // idempotent reports whether repeating a request with the same Idempotency-Key replays its response.
+}
+
+// routes returns the route table served by the controller.
+func (c *controller) routes() []route {
+	return []route{
+		{method: http.MethodGet, path: healthPath, handler: healthHandler, summary: "Check that the server is alive",
+			status: http.StatusOK, response: models.HealthResponse{}},
+		{method: http.MethodGet, path: readinessPath, handler: c.readinessHandler, summary: "Check that the server is ready to serve requests",
+			status: http.StatusOK, response: models.HealthResponse{}},
+		{method: http.MethodGet, path: "/time", handler: c.timeHandler, summary: "Get the server's time and time zone",
+			status: http.StatusOK, response: models.TimeResponse{}},
+		{method: http.MethodPost, path: "/tenants", handler: c.createTenantHandler, summary: "Create a tenant",
+			status: http.StatusCreated, request: models.CreateTenantBody{}, response: models.TenantResponse{}, idempotent: true},
+		{method: http.MethodGet, path: "/tenants/available", handler: c.availabilityHandler, summary: "Check whether a subdomain is available",
+			status: http.StatusOK, response: models.AvailabilityResponse{}},
+		{method: http.MethodGet, path: "/tenants/:id", handler: c.getTenantHandler, summary: "Get a tenant",
+			status: http.StatusOK, response: models.TenantResponse{}},
+		{method: http.MethodDelete, path: "/tenants/:id", handler: c.deleteTenantHandler, summary: "Delete a tenant",
+			status: http.StatusOK, response: models.OffboardPreviewResponse{}, destructive: true},
+		{method: http.MethodPost, path: "/tenants/:id/export", handler: c.exportTenantHandler, summary: "Export a tenant's data",
+			status: http.StatusOK, response: models.ExportTenantResponse{}, admin: true},
+		{method: http.MethodPost, path: "/tenants/:id/suspend", handler: c.suspendTenantHandler, summary: "Suspend a tenant",
+			status: http.StatusOK, response: models.TenantResponse{}, admin: true},
+		{method: http.MethodPost, path: "/tenants/:id/activate", handler: c.activateTenantHandler, summary: "Reactivate a suspended tenant",
+			status: http.StatusOK, response: models.TenantResponse{}, admin: true},
+		{method: http.MethodGet, path: "/tenants/:id/books", handler: c.getTenantBooksHandler, summary: "List any tenant's books",
+			status: http.StatusOK, response: []models.BookResponse{}, admin: true},
+		{method: http.MethodGet, path: "/tenants/jobs/:id", handler: c.getJobHandler, summary: "Get a background job",
+			status: http.StatusOK, response: models.JobResponse{}},
+		{method: http.MethodGet, path: "/tenants/jobs/:id/logs", handler: c.jobLogsHandler, summary: "Stream a background job's progress",
+			status: http.StatusOK, response: models.JobEvent{}, stream: true, mediaType: MIMETextEventStream},
+		{method: http.MethodPost, path: "/tenants/:id/migrate", handler: c.migrateTenantHandler, summary: "Migrate a tenant's schema",
+			status: http.StatusOK, response: models.TenantResponse{}, admin: true},
+		{method: http.MethodPost, path: "/tenants/migrate", handler: c.migrateTenantsHandler, summary: "Migrate every tenant's schema",
+			status: http.StatusOK, response: models.MigrateTenantsResponse{}, admin: true},
+		{method: http.MethodPost, path: "/tenants/migrate/stream", handler: c.migrateTenantsStreamHandler, summary: "Migrate every tenant's schema, streaming progress",
+			status: http.StatusOK, response: models.MigrationProgress{}, admin: true, stream: true, mediaType: MIMEApplicationNDJSON},
+		{method: http.MethodGet, path: "/books", handler: c.getBooksHandler, summary: "List books",
+			status: http.StatusOK, response: []models.BookResponse{}, tenant: true, cost: 5},
+		{method: http.MethodGet, path: "/books/count", handler: c.bookCountHandler, summary: "Count books",
+			status: http.StatusOK, response: models.BookCountResponse{}, tenant: true},
+		{method: http.MethodGet, path: "/books/export", handler: c.exportBooksHandler, summary: "Export books as CSV",
+			status: http.StatusOK, tenant: true, cost: 5, stream: true, mediaType: MIMETextCSV},
+		{method: http.MethodGet, path: "/books/:id", handler: c.getBookHandler, summary: "Get a book",
+			status: http.StatusOK, response: models.BookResponse{}, tenant: true},
+		{method: http.MethodPost, path: "/books", handler: c.createBookHandler, summary: "Create a book",
+			status: http.StatusCreated, request: models.CreateBookBody{}, response: models.BookResponse{}, tenant: true, cost: 2, idempotent: true},
+		{method: http.MethodDelete, path: "/books", handler: c.batchDeleteBooksHandler, summary: "Delete books in bulk",
+			status: http.StatusOK, request: models.BatchDeleteBooksBody{}, response: models.BatchDeleteBooksResponse{}, tenant: true, cost: 5},
+		{method: http.MethodDelete, path: "/books/:id", handler: c.deleteBookHandler, summary: "Delete a book",
+			status: http.StatusNoContent, tenant: true, cost: 2},
+		{method: http.MethodPut, path: "/books/:id", handler: c.updateBookHandler, summary: "Update a book",
+			status: http.StatusOK, request: models.UpdateBookBody{}, tenant: true, cost: 2},
+		{method: http.MethodPost, path: "/admin/reconcile", handler: c.reconcileHandler, summary: "Reconcile tenant rows with schemas",
+			status: http.StatusOK, response: models.ReconcileResponse{}, admin: true, destructive: true},
+		{method: http.MethodGet, path: "/admin/books/stats", handler: c.bookStatsHandler, summary: "Count every tenant's books",
+			status: http.StatusOK, response: models.BookStatsResponse{}, admin: true},
+		{method: http.MethodGet, path: "/admin/cache/stats", handler: c.cacheStatsHandler, summary: "Report cache statistics",
+			status: http.StatusOK, response: map[string]CacheStats{}, admin: true},
+		{method: http.MethodPost, path: "/admin/cache/clear", handler: c.clearCachesHandler, summary: "Clear all caches",
+			status: http.StatusNoContent, admin: true, destructive: true},
+	}
+}
+
+// basePath returns the prefix the routes are mounted under, such as
+// "/api/v1", or "" to mount them at the root.
+func (c *controller) basePath() string {
+	p := strings.TrimSuffix(c.opts.BasePath, "/")
+	if p != "" && !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
 
-	e.POST("/tenants", c.createTenantHandler)
-	e.GET("/tenants/:id", c.getTenantHandler)
-	e.DELETE("/tenants/:id", c.deleteTenantHandler)
-	e.GET("/books", c.getBooksHandler)
-	e.POST("/books", c.createBookHandler)
-	e.DELETE("/books/:id", c.deleteBookHandler)
-	e.PUT("/books/:id", c.updateBookHandler)
+// routePath returns path relative to the base path, reporting whether path
+// is under it at all.
+func (c *controller) routePath(path string) (string, bool) {
+	base := c.basePath()
+	rel, ok := strings.CutPrefix(path, base)
+	if !ok || (rel != "" && !strings.HasPrefix(rel, "/")) {
+		return path, false
+	}
+	return rel, true
+}
+
+// skipTenant reports whether the request path is served without resolving a
+// tenant. Paths outside the base path are left for the router to reject.
+func (c *controller) skipTenant(path string) bool {
+	rel, ok := c.routePath(path)
+	return !ok || skipTenant(rel)
+}
+
+// skipTenant reports whether path, relative to the base path, is served
+// without resolving a tenant.
+func skipTenant(path string) bool {
+	return strings.HasPrefix(path, "/tenants") || // skip tenant routes
+		strings.HasPrefix(path, "/admin") || // skip admin routes
+		path == "/openapi.json" || path == "/docs" || // skip API docs
+		isHealthPath(path) || // skip health probes
+		path == "/time" // skip the clock, which reports the tenant only if any
+}
+
+// Server is an HTTP server over a database. Once it has shut down it may be
+// started again.
+type Server struct {
+	cr *controller
 }
 
-func Start(ctx context.Context, db *multitenancy.DB) error {
+// NewServer returns a server over db configured by opts.
+func NewServer(db *multitenancy.DB, opts ...Option) *Server {
 	cr := &controller{db: db}
-	return cr.start(ctx)
+	for _, opt := range opts {
+		opt(&cr.opts)
+	}
+	return &Server{cr: cr}
+}
+
+// Start serves requests until ctx is done, then shuts the server down. It
+// returns [ErrAlreadyRunning] if the server is running already.
+func (s *Server) Start(ctx context.Context) error {
+	return s.cr.start(ctx)
+}
+
+// Start serves requests over db until ctx is done.
+func Start(ctx context.Context, db *multitenancy.DB, opts ...Option) error {
+	return NewServer(db, opts...).Start(ctx)
 }
 
 func (cr *controller) start(ctx context.Context) (err error) {
-	cr.once.Do(func() {
-		e := echo.New()
-		cr.init(e)
+	cr.mu.Lock()
+	if cr.state == stateRunning {
+		cr.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	cr.state = stateRunning
+	cr.mu.Unlock()
+	defer func() {
+		cr.mu.Lock()
+		cr.state = stateIdle
+		cr.mu.Unlock()
+	}()
 
-		srv := &http.Server{
-			Addr:         ":8080",
-			Handler:      e,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
+	// Jobs and streams of a previous run were canceled on its shutdown.
+	cr.jobs = newJobStore()
+	cr.streams = newStreamTracker()
+	if err = cr.configurePool(); err != nil {
+		return fmt.Errorf("configuring database pool: %w", err)
+	}
+	e := echo.New()
+	cr.init(e)
+	if cr.opts.SelfTest {
+		if err = cr.selfTest(ctx); err != nil {
+			return fmt.Errorf("startup self-test: %w", err)
 		}
+	}
+	cr.startWorkers()
 
-		go func() {
-			if serveErr := e.StartServer(srv); serveErr != nil {
-				log.Printf("listen: %s\n", serveErr)
-				err = serveErr
-			}
-		}()
+	addr := cr.opts.Addr
+	if addr == "" {
+		addr = defaultAddr
+	}
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      e,
+		ReadTimeout:  cr.opts.ReadTimeout,
+		WriteTimeout: cr.opts.WriteTimeout,
+	}
+	if srv.ReadTimeout <= 0 {
+		srv.ReadTimeout = defaultReadTimeout
+	}
+	if srv.WriteTimeout <= 0 {
+		srv.WriteTimeout = defaultWriteTimeout
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- e.StartServer(srv)
+	}()
 
-		<-ctx.Done()
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		log.Printf("listen: %s\n", err)
+	}
 
-		ctxShutdown, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
+	// ctx is done, so the shutdown gets a budget of its own.
+	timeout := cr.opts.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctxShutdown, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
 
-		if shutdownErr := e.Shutdown(ctxShutdown); shutdownErr != nil {
-			log.Printf("Server forced to shutdown: %v", shutdownErr)
-			if err == nil {
-				err = shutdownErr
-			}
+	if shutdownErr := cr.shutdown(ctxShutdown, srv); shutdownErr != nil {
+		log.Printf("Server forced to shutdown: %v", shutdownErr)
+		if err == nil {
+			err = shutdownErr
 		}
+	}
 
-		log.Println("Server exiting")
-	})
+	log.Println("Server exiting")
 	return err
 }
 
@@ -90,140 +399,482 @@ func TenantFromContext(c echo.Context) (string, error) {
 	return tenantID, nil
 }
 
+// mustTenant returns the tenant of a request served for a tenant. A request
+// that reaches such a handler without one did not identify its tenant, which
+// is the client's fault, so it fails with 400 rather than 500.
+func mustTenant(c echo.Context) (string, error) {
+	tenantID, err := TenantFromContext(c)
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "tenant is required")
+	}
+	return tenantID, nil
+}
+
 func (cr *controller) createTenantHandler(c echo.Context) error {
 	var body models.CreateTenantBody
 	var err error
 	if err = c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	if err = c.Validate(&body); err != nil {
+		return err
+	}
 	subdomain, subdomainErr := echomw.ExtractSubdomain(body.DomainURL)
 	if subdomainErr != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, subdomainErr.Error())
 	}
+	if cr.isReservedSchema(subdomain) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%q is a reserved schema name", subdomain))
+	}
+	if !validSchemaName(subdomain) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%q is not a valid schema name", subdomain))
+	}
 	tenant := &models.Tenant{
 		TenantModel: multitenancy.TenantModel{
 			DomainURL:  body.DomainURL,
 			SchemaName: subdomain,
 		},
+		Tier:            body.Tier,
+		DefaultPageSize: body.DefaultPageSize,
+		MaxPageSize:     body.MaxPageSize,
+		Timezone:        body.Timezone,
+	}
+	withSeed, err := queryBool(c, "seed")
+	if err != nil {
+		return err
+	}
+	var seed []models.Book
+	if withSeed {
+		seed = sampleBooks()
+	}
+	async, err := queryBool(c, "async")
+	if err != nil {
+		return err
+	}
+	tenant.Status = models.TenantActive
+	if cr.opts.DisableAutoMigrate {
+		if withSeed {
+			return echo.NewHTTPError(http.StatusBadRequest, "seeding requires the tenant to be migrated on creation")
+		}
+		tenant.Status = models.TenantPending
+	}
+	ctx := c.Request().Context()
+	// The unique domain and schema name are claimed before the schema is
+	// created, so a duplicate leaves nothing to clean up.
+	if err = cr.db.WithContext(ctx).Create(tenant).Error; err != nil {
+		if cr.isUniqueViolation(err) {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("tenant %q already exists", subdomain))
+		}
+		return err
+	}
+	tenantID := strconv.FormatUint(uint64(tenant.ID), 10)
+	if tenant.Status == models.TenantPending {
+		// The tenant is served once POST /tenants/:id/migrate creates its
+		// schema.
+		cr.audit(ctx, tenant.SchemaName, AuditCreate, AuditTenant, tenantID)
+		return respond(c, http.StatusCreated, tenantResponse(tenant))
+	}
+	if async {
+		// Finish the onboarding even if the client goes away.
+		ctx := context.WithoutCancel(ctx)
+		res := cr.jobs.start("onboard", tenant.SchemaName, func(jobCtx context.Context) error {
+			if err := cr.onboardTenant(jobCtx, tenant, seed); err != nil {
+				return err
+			}
+			cr.tenants.Add(tenant.SchemaName)
+			cr.audit(ctx, tenant.SchemaName, AuditCreate, AuditTenant, tenantID)
+			return nil
+		})
+		c.Response().Header().Set(echo.HeaderLocation, cr.basePath()+"/tenants/jobs/"+res.ID)
+		return respond(c, http.StatusAccepted, res)
 	}
-	if err = cr.db.Create(tenant).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	if err = cr.onboardTenant(ctx, tenant, seed); err != nil {
+		return err
 	}
-	if err = cr.db.MigrateTenantModels(context.Background(), tenant.SchemaName); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	cr.tenants.Add(tenant.SchemaName)
+	cr.audit(ctx, tenant.SchemaName, AuditCreate, AuditTenant, tenantID)
+	return respond(c, http.StatusCreated, tenantResponse(tenant))
+}
+
+func tenantResponse(tenant *models.Tenant) *models.TenantResponse {
+	return &models.TenantResponse{
+		ID:              tenant.ID,
+		DomainURL:       tenant.DomainURL,
+		Tier:            tenant.Tier,
+		DefaultPageSize: tenant.DefaultPageSize,
+		MaxPageSize:     tenant.MaxPageSize,
+		Timezone:        tenant.Timezone,
+		Status:          tenant.Status,
 	}
+}
 
-	res := &models.TenantResponse{
-		ID:        tenant.ID,
-		DomainURL: tenant.DomainURL,
+// tenantIDParam returns the tenant ID of the request's id path parameter,
+// rejecting anything but a positive integer before it reaches a query.
+func tenantIDParam(c echo.Context) (uint64, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "tenant id must be a positive integer")
 	}
-	return c.JSON(http.StatusCreated, res)
+	return id, nil
 }
 
 func (cr *controller) getTenantHandler(c echo.Context) error {
-	dbName := cr.db.Migrator().CurrentDatabase()
-	fmt.Println("Database Name:", dbName)
-	tenantID := c.Param("id")
+	id, err := tenantIDParam(c)
+	if err != nil {
+		return err
+	}
+	// Select the response's columns only, leaving offboarded tenants out.
 	tenant := &models.TenantResponse{}
-	if err := cr.db.Table(models.TableNameTenant).First(tenant, tenantID).Error; err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	if err = cr.db.WithContext(c.Request().Context()).Model(&models.Tenant{}).
+		Select("id", "domain_url", "tier", "default_page_size", "max_page_size", "timezone", "status").
+		Where("id = ?", id).Take(tenant).Error; err != nil {
+		return err
 	}
-	return c.JSON(http.StatusOK, tenant)
+	return respond(c, http.StatusOK, tenant)
 }
 
 func (cr *controller) deleteTenantHandler(c echo.Context) error {
-	tenantID := c.Param("id")
+	async, err := queryBool(c, "async")
+	if err != nil {
+		return err
+	}
+	archive, err := queryBool(c, "archive")
+	if err != nil {
+		return err
+	}
+	confirm, err := queryBool(c, "confirm")
+	if err != nil {
+		return err
+	}
+	if archive && cr.opts.Exporter == nil {
+		return errNoExporter()
+	}
+	id, err := tenantIDParam(c)
+	if err != nil {
+		return err
+	}
 	tenant := &models.Tenant{}
-	var err error
-	if err = cr.db.First(tenant, tenantID).Error; err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	if err = cr.db.WithContext(c.Request().Context()).First(tenant, id).Error; err != nil {
+		return err
 	}
-	if err = cr.db.OffboardTenant(context.Background(), tenant.SchemaName); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	// Nothing is dropped unless asked for explicitly.
+	if !confirm {
+		preview, err := cr.offboardPreview(c.Request().Context(), tenant)
+		if err != nil {
+			return err
+		}
+		return respond(c, http.StatusOK, preview)
+	}
+	// Finish the offboard even if the client goes away.
+	ctx := context.WithoutCancel(c.Request().Context())
+	tenantID := strconv.FormatUint(uint64(tenant.ID), 10)
+	if !async {
+		if err = cr.removeTenant(ctx, tenant, archive); err != nil {
+			if errors.Is(err, errReservedSchema) {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+			return err
+		}
+		cr.audit(ctx, tenant.SchemaName, AuditDelete, AuditTenant, tenantID)
+		return c.NoContent(http.StatusNoContent)
 	}
-	if err = cr.db.Delete(&models.Tenant{}, tenantID).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+
+	// Refuse reserved schemas up front, rather than in a job nobody polls.
+	if cr.isReservedSchema(tenant.SchemaName) {
+		return echo.NewHTTPError(http.StatusForbidden,
+			fmt.Sprintf("refusing to offboard %q: %v", tenant.SchemaName, errReservedSchema))
 	}
-	return c.NoContent(http.StatusNoContent)
+	res := cr.jobs.start("offboard", tenant.SchemaName, func(jobCtx context.Context) error {
+		if err := cr.removeTenant(jobCtx, tenant, archive); err != nil {
+			return err
+		}
+		cr.audit(ctx, tenant.SchemaName, AuditDelete, AuditTenant, tenantID)
+		return nil
+	})
+	c.Response().Header().Set(echo.HeaderLocation, cr.basePath()+"/tenants/jobs/"+res.ID)
+	return respond(c, http.StatusAccepted, res)
+}
+
+// removeTenant drops the tenant's schema, then deletes its counter and row.
+// With archive, the tenant's data is exported first, and a failed export
+// leaves the tenant untouched.
+func (cr *controller) removeTenant(ctx context.Context, tenant *models.Tenant, archive bool) error {
+	if archive {
+		if cr.isReservedSchema(tenant.SchemaName) {
+			return fmt.Errorf("refusing to offboard %q: %w", tenant.SchemaName, errReservedSchema)
+		}
+		if _, err := cr.exportTenant(ctx, tenant); err != nil {
+			return err
+		}
+	}
+	if err := cr.offboardTenant(ctx, tenant.SchemaName); err != nil {
+		return err
+	}
+	cr.tenants.Remove(tenant.SchemaName)
+	db, err := cr.tenantDB(ctx, tenant.SchemaName)
+	if err != nil {
+		return err
+	}
+	if err = db.Delete(&models.BookCounter{}, "tenant_schema = ?", tenant.SchemaName).Error; err != nil {
+		return err
+	}
+	return cr.db.WithContext(ctx).Delete(&models.Tenant{}, tenant.ID).Error
+}
+
+// queryBool parses the optional boolean query parameter name.
+func queryBool(c echo.Context, name string) (bool, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusBadRequest, name+" must be a boolean")
+	}
+	return b, nil
 }
 
 func (cr *controller) getBooksHandler(c echo.Context) error {
-	tenantID, err := TenantFromContext(c)
+	tenantID, err := mustTenant(c)
+	if err != nil {
+		return err
+	}
+	shape := c.QueryParam("shape")
+	switch shape {
+	case "", "array":
+	case "map":
+		if c.QueryParam("view") != "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "shape \"map\" is not supported with a view")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "shape must be \"array\" or \"map\"")
+	}
+	switch c.QueryParam("view") {
+	case "":
+	case "flat":
+		return cr.getFlatBooksHandler(c, tenantID)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "view must be empty or \"flat\"")
+	}
+	lastModified, err := cr.booksLastModified(c, tenantID)
+	if err != nil {
+		return err
+	}
+	if !lastModified.IsZero() {
+		c.Response().Header().Set(echo.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+		if ims := c.Request().Header.Get(echo.HeaderIfModifiedSince); ims != "" && notModifiedSince(ims, lastModified) {
+			return c.NoContent(http.StatusNotModified)
+		}
+	}
+	books, err := cr.listBooks(c, tenantID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
+	}
+	if shape == "map" {
+		return cr.respondProjected(c, tenantID, http.StatusOK, booksByID(books))
+	}
+	return cr.respondProjected(c, tenantID, http.StatusOK, books)
+}
+
+// booksByID keys books by their ID, for clients looking books up directly.
+// Objects are unordered: clients paging through the map must follow
+// [HeaderXNextCursor] rather than the order of its keys.
+func booksByID(books []models.BookResponse) map[string]models.BookResponse {
+	m := make(map[string]models.BookResponse, len(books))
+	for _, b := range books {
+		m[strconv.FormatUint(uint64(b.ID), 10)] = b
+	}
+	return m
+}
+
+// listBooks returns the page of the tenant's books requested by the query
+// parameters of c, setting the cursor of the next page if there is one.
+func (cr *controller) listBooks(c echo.Context, tenantID string) ([]models.BookResponse, error) {
+	p, err := cr.parsePage(c, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	db, err := cr.tenantDB(c.Request().Context(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	q := db.Table(models.TableNameBook).Scopes(scopes.WithTenantSchema(tenantID), filterBooks(c)).Order("id")
+	if p.after > 0 {
+		q = q.Where("id > ?", p.after)
+	} else {
+		q = q.Offset(p.offset)
 	}
 	var books []models.BookResponse
-	if err = cr.db.Table(models.TableNameBook).Scopes(scopes.WithTenantSchema(tenantID)).Find(&books).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	// Fetch one book more than requested to tell whether there is a next page.
+	if err = q.Limit(p.limit + 1).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	if len(books) > p.limit {
+		books = books[:p.limit]
+		setNextCursor(c, books[p.limit-1].ID)
 	}
-	return c.JSON(http.StatusOK, books)
+	return books, nil
 }
 
 func (cr *controller) createBookHandler(c echo.Context) error {
-	tenantID, err := TenantFromContext(c)
+	tenantID, err := mustTenant(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
-	var book models.Book
-	if err = c.Bind(&book); err != nil {
+	// Bind into the body rather than the model, so that clients cannot set
+	// the ID, version or timestamps, nor any association but new tags.
+	var body models.CreateBookBody
+	if err = c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	book.TenantSchema = tenantID
-	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
-	if tenantErr != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, tenantErr.Error())
+	if err = c.Validate(&body); err != nil {
+		return err
+	}
+	if body.ISBN != "" {
+		if body.ISBN, err = models.NormalizeISBN(body.ISBN); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+	book := models.Book{Name: body.Name, Author: body.Author, ISBN: body.ISBN, TenantSchema: tenantID}
+	for _, tag := range body.Tags {
+		book.Tags = append(book.Tags, models.Tag{Name: tag.Name})
 	}
-	defer reset()
-	if err = cr.db.Create(&book).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	if err = cr.withTenantTx(c.Request().Context(), tenantID, func(tx *multitenancy.DB) error {
+		restored, err := cr.restoreDeletedBook(tx, tenantID, &book)
+		if err != nil {
+			return err
+		}
+		if !restored {
+			if err := tx.Create(&book).Error; err != nil {
+				return err
+			}
+		}
+		return adjustBookCount(tx.DB, tenantID, 1)
+	}); err != nil {
+		return err
 	}
+	cr.audit(c.Request().Context(), tenantID, AuditCreate, AuditBook, strconv.FormatUint(uint64(book.ID), 10))
 
 	res := &models.BookResponse{
-		ID:   book.ID,
-		Name: book.Name,
+		ID:      book.ID,
+		Name:    book.Name,
+		Author:  book.Author,
+		ISBN:    book.ISBN,
+		Version: book.Version,
+	}
+	return respond(c, http.StatusCreated, res)
+}
+
+// bookIDParam returns the book ID of the request's id path parameter,
+// rejecting anything but a positive integer before it reaches a query.
+func bookIDParam(c echo.Context) (uint64, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "book id must be a positive integer")
 	}
-	return c.JSON(http.StatusCreated, res)
+	return id, nil
 }
 
 func (cr *controller) deleteBookHandler(c echo.Context) error {
-	tenantID, err := TenantFromContext(c)
+	tenantID, err := mustTenant(c)
+	if err != nil {
+		return err
+	}
+	bookID, err := bookIDParam(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
+	}
+	db, err := cr.tenantDB(c.Request().Context(), tenantID)
+	if err != nil {
+		return err
 	}
-	bookID := c.Param("id")
 	var book models.Book
-	if err = cr.db.Scopes(scopes.WithTenantSchema(tenantID)).First(&book, bookID).Error; err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	if err = db.Scopes(scopes.WithTenantSchema(tenantID)).First(&book, bookID).Error; err != nil {
+		return err
+	}
+	var deleted int64
+	if err = db.Transaction(func(tx *multitenancy.DB) error {
+		res := tx.Scopes(scopes.WithTenantSchema(tenantID)).Delete(&models.Book{}, bookID)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		deleted = res.RowsAffected
+		return adjustBookCount(tx.DB, tenantID, -1)
+	}); err != nil {
+		return err
 	}
-	if err = cr.db.Scopes(scopes.WithTenantSchema(tenantID)).Delete(&models.Book{}, bookID).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	if deleted > 0 {
+		cr.audit(c.Request().Context(), tenantID, AuditDelete, AuditBook, strconv.FormatUint(bookID, 10))
 	}
+	setAffectedRows(c, deleted)
 	return c.NoContent(http.StatusNoContent)
 }
 
 func (cr *controller) updateBookHandler(c echo.Context) error {
-	tenantID, err := TenantFromContext(c)
+	tenantID, err := mustTenant(c)
+	if err != nil {
+		return err
+	}
+	bookID, err := bookIDParam(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return err
 	}
-	bookID := c.Param("id")
 	var body models.UpdateBookBody
 	if err = c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	if body.Name == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	if err = c.Validate(&body); err != nil {
+		return err
 	}
-	book := &models.Book{}
-	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
-	if tenantErr != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, tenantErr.Error())
+	if body.ISBN != "" {
+		if body.ISBN, err = models.NormalizeISBN(body.ISBN); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 	}
-	defer reset()
-	if err = cr.db.Model(book).Where("id = ?", bookID).Updates(models.Book{
-		Name: body.Name,
-	}).Error; err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	fields := body.Fields()
+	var updated int64
+	if err = cr.withTenantTx(c.Request().Context(), tenantID, func(tx *multitenancy.DB) error {
+		res := tx.Model(&models.Book{}).Where("id = ? AND version = ?", bookID, body.Version).Updates(fields)
+		if updated = res.RowsAffected; res.Error != nil || updated > 0 {
+			return res.Error
+		}
+		var n int64
+		if err := tx.Model(&models.Book{}).Where("id = ?", bookID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return echo.NewHTTPError(http.StatusConflict, "book was modified concurrently, fetch it and retry")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if updated > 0 {
+		cr.audit(c.Request().Context(), tenantID, AuditUpdate, AuditBook, strconv.FormatUint(bookID, 10))
 	}
+	setAffectedRows(c, updated)
 	return c.NoContent(http.StatusOK)
 }
+
+// withTenantTx runs fn in a transaction with the tenant in use. The tenant
+// switch is made on the transaction's dedicated connection and reset before
+// the connection is released, so it is never observed by other requests
+// sharing the pool.
+func (cr *controller) withTenantTx(ctx context.Context, tenantID string, fn func(tx *multitenancy.DB) error) error {
+	db, err := cr.tenantDB(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	return withTenantTx(ctx, db, tenantID, fn)
+}
+
+func withTenantTx(ctx context.Context, db *multitenancy.DB, tenantID string, fn func(tx *multitenancy.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *multitenancy.DB) error {
+		reset, err := tx.UseTenant(ctx, tenantID)
+		if err != nil {
+			return err
+		}
+		defer reset()
+		return fn(tx)
+	})
+}
@@ -2,12 +2,20 @@ package echoserver
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
 	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/servertest"
 	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
 	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // MakeHandler implements [servertest.Harness].
@@ -23,3 +31,113 @@ func (c *controller) MakeHandler(ctx context.Context, db *multitenancy.DB) (http
 func TestEchoServer(t *testing.T) {
 	servertest.RunConformance(t, &controller{})
 }
+
+func TestHandlersWithoutTenant(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		handler echo.HandlerFunc
+	}{
+		{"create book", http.MethodPost, `{"name": "Dune", "author": "Frank Herbert"}`, cr.createBookHandler},
+		{"update book", http.MethodPut, `{"name": "Dune"}`, cr.updateBookHandler},
+		{"delete book", http.MethodDelete, "", cr.deleteBookHandler},
+		{"delete books", http.MethodDelete, `{"ids": [1]}`, cr.batchDeleteBooksHandler},
+		{"list books", http.MethodGet, "", cr.getBooksHandler},
+		{"get book", http.MethodGet, "", cr.getBookHandler},
+		{"count books", http.MethodGet, "", cr.bookCountHandler},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/books", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := e.NewContext(req, httptest.NewRecorder())
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+
+			var he *echo.HTTPError
+			require.True(t, errors.As(tt.handler(c), &he), "handler fails with an HTTP error")
+			assert.Equal(t, http.StatusBadRequest, he.Code)
+		})
+	}
+}
+
+func TestServerRestart(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	srv := NewServer(newSQLiteDB(t), WithAddr(addr))
+
+	for run := range 2 {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- srv.Start(ctx) }()
+
+		require.Eventually(t, func() bool {
+			res, err := http.Get("http://" + addr + healthPath)
+			if err != nil {
+				return false
+			}
+			res.Body.Close()
+			return res.StatusCode == http.StatusOK
+		}, 5*time.Second, 10*time.Millisecond, "run %d serves requests", run)
+		assert.ErrorIs(t, srv.Start(context.Background()), ErrAlreadyRunning, "run %d", run)
+
+		cancel()
+		select {
+		case err := <-done:
+			require.NoError(t, err, "run %d shuts down cleanly", run)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("run %d did not shut down", run)
+		}
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	_, e := newSQLiteServer(t, WithBasePath("api/v1/"))
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/api/v1/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/api/v1/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = serve(e, http.MethodGet, "/api/v1/books", host, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `[{"id": 1, "name": "Dune", "author": "Frank Herbert", "version": 1}]`, rr.Body.String())
+	for _, path := range []string{"/api/v1/tenants/1", "/api/v1/healthz", "/api/v1/openapi.json"} {
+		rr = serve(e, http.MethodGet, path, "", "")
+		assert.Equal(t, http.StatusOK, rr.Code, path)
+	}
+	assert.Contains(t, rr.Body.String(), `"servers":[{"url":"/api/v1"}]`)
+
+	for _, path := range []string{"/books", "/tenants/1", "/api/v1x/books"} {
+		rr = serve(e, http.MethodGet, path, host, "")
+		assert.Equal(t, http.StatusNotFound, rr.Code, "%s is outside the base path", path)
+	}
+
+	rr = serve(e, http.MethodDelete, "/api/v1/tenants/1?async=true&confirm=true", "", "")
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	assert.True(t, strings.HasPrefix(rr.Header().Get(echo.HeaderLocation), "/api/v1/tenants/jobs/"))
+}
+
+func TestGetTenant(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com", "tier": "free"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = serve(e, http.MethodGet, "/tenants/1", "", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"id": 1, "domainUrl": "tenant1.example.com", "tier": "free", "status": "active"}`, rr.Body.String())
+
+	for _, id := range []string{"abc", "-1", "1.5"} {
+		rr = serve(e, http.MethodGet, "/tenants/"+id, "", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "%s: %s", id, rr.Body.String())
+		assert.Equal(t, "tenant id must be a positive integer", decode[models.ErrorResponse](t, rr).Message, id)
+	}
+
+	require.NoError(t, cr.db.Delete(&models.Tenant{}, 1).Error)
+	rr = serve(e, http.MethodGet, "/tenants/1", "", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "an offboarded tenant is not found: %s", rr.Body.String())
+}
@@ -0,0 +1,123 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultShutdownTimeout = 5 * time.Second
+
+// startWorkers starts the background workers. They run until shutdown.
+func (cr *controller) startWorkers() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cr.stopWorkers = cancel
+	cr.workers.Add(1)
+	go func() {
+		defer cr.workers.Done()
+		cr.reconcileBookCountsLoop(ctx)
+	}()
+	if cr.opts.JanitorInterval > 0 {
+		cr.workers.Add(1)
+		go func() {
+			defer cr.workers.Done()
+			cr.janitorLoop(ctx)
+		}()
+	}
+}
+
+// shutdown stops the server in a strict order, so that nothing uses the
+// database once it is closed:
+//
+//  1. ask the streaming responses to end, and wait for them within
+//     [Options.StreamDrainTimeout];
+//  2. stop accepting connections and drain the in-flight requests,
+//     closing the connections, streams included, still open when ctx is
+//     done;
+//  3. stop the background workers and jobs, and wait for them to return;
+//  4. run the shutdown hooks, in the order they were added;
+//  5. close the database, if [Options.CloseDB] is set.
+//
+// A failing step does not prevent the later ones, except that the database
+// is left open if requests or workers may still be running.
+func (cr *controller) shutdown(ctx context.Context, srv *http.Server) error {
+	var errs []error
+	drained := true
+	if cr.streams != nil {
+		if err := cr.closeStreams(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("close streams: %w", err))
+		}
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain requests: %w", err))
+		drained = false
+		if cr.streams != nil {
+			cr.logger().WarnContext(ctx, "Closing connections forcibly", "streams", cr.streams.open())
+		}
+		_ = srv.Close()
+	}
+
+	if cr.stopWorkers != nil {
+		cr.stopWorkers()
+	}
+	if err := wait(ctx, &cr.workers); err != nil {
+		errs = append(errs, fmt.Errorf("stop workers: %w", err))
+		drained = false
+	}
+	if cr.jobs != nil {
+		if err := cr.jobs.shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop jobs: %w", err))
+			drained = false
+		}
+	}
+
+	for i, hook := range cr.opts.ShutdownHooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %d: %w", i, err))
+		}
+	}
+
+	if cr.opts.CloseDB && cr.db != nil {
+		if !drained {
+			errs = append(errs, errors.New("database left open: requests or workers may still be running"))
+		} else if sqlDB, err := cr.db.DB.DB(); err != nil {
+			errs = append(errs, fmt.Errorf("close database: %w", err))
+		} else if err := sqlDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close database: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeStreams asks the streaming responses to end and waits for them,
+// within [Options.StreamDrainTimeout] if set.
+func (cr *controller) closeStreams(ctx context.Context) error {
+	if timeout := cr.opts.StreamDrainTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := cr.streams.closeAll(ctx); err != nil {
+		cr.logger().WarnContext(ctx, "Streams did not end in time", "streams", cr.streams.open())
+		return err
+	}
+	return nil
+}
+
+// wait waits for wg, or for ctx to be done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
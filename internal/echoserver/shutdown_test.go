@@ -0,0 +1,110 @@
+package echoserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			event += ": " + err.Error()
+		}
+		events = append(events, event)
+	}
+	var cr *controller
+	touchDB := func(ctx context.Context) error {
+		var n int64
+		return cr.db.WithContext(ctx).Model(&models.Tenant{}).Count(&n).Error
+	}
+
+	cr, e := newSQLiteServer(t, WithCloseDB(), WithShutdownHook(func(ctx context.Context) error {
+		record("hook", touchDB(ctx))
+		return nil
+	}))
+	started, release := make(chan struct{}), make(chan struct{})
+	e.GET("/admin/slow", func(c echo.Context) error {
+		close(started)
+		<-release
+		record("request", touchDB(context.Background()))
+		return c.NoContent(http.StatusNoContent)
+	})
+	cr.startWorkers()
+	cr.jobs.start("test", "tenant1", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // still cleaning up after shutdown began
+		record("job", touchDB(context.Background()))
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: e}
+	go func() { _ = srv.Serve(ln) }()
+	url := "http://" + ln.Addr().String()
+
+	reqErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get(url + "/admin/slow")
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode != http.StatusNoContent {
+				err = assert.AnError
+			}
+		}
+		reqErr <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- cr.shutdown(ctx, srv) }()
+
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, 5*time.Second, 5*time.Millisecond, "new connections are refused while draining")
+	close(release)
+
+	require.NoError(t, <-reqErr, "in-flight request completes")
+	require.NoError(t, <-shutdownErr)
+	assert.Equal(t, []string{"request", "job", "hook"}, events, "nothing touches the database after it is closed")
+	assert.Error(t, touchDB(context.Background()), "database is closed last")
+}
+
+func TestShutdownLeavesDBOpenWhenNotDrained(t *testing.T) {
+	cr, _ := newSQLiteServer(t, WithCloseDB())
+	release := make(chan struct{})
+	defer close(release)
+	cr.jobs.start("test", "tenant1", func(context.Context) error {
+		<-release // ignores cancellation
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := cr.shutdown(ctx, &http.Server{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "database left open")
+	var n int64
+	assert.NoError(t, cr.db.Model(&models.Tenant{}).Count(&n).Error, "a running job can still use the database")
+}
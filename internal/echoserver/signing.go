@@ -0,0 +1,126 @@
+package echoserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers of signed requests, see [SignRequest].
+const (
+	HeaderXSignature          = "X-Signature"
+	HeaderXSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderXSignatureNonce     = "X-Signature-Nonce"
+)
+
+const defaultSignatureMaxAge = 5 * time.Minute
+
+// SignRequest signs req for the destructive routes guarded by
+// [Options.SigningKey]. The signature is an HMAC-SHA256, keyed with key, of
+// the method, the request URI, the Unix time now, the nonce and the SHA-256 of
+// the body. The nonce must be unique: a request is only accepted once.
+func SignRequest(req *http.Request, key []byte, nonce string, now time.Time) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(HeaderXSignatureTimestamp, ts)
+	req.Header.Set(HeaderXSignatureNonce, nonce)
+	req.Header.Set(HeaderXSignature, signature(key, req.Method, req.URL.RequestURI(), ts, nonce, body))
+	return nil
+}
+
+func signature(key []byte, method, uri, ts, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	for _, part := range []string{method, uri, ts, nonce, hex.EncodeToString(sum[:])} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// nonceStore remembers the nonces of accepted requests until their timestamp
+// is too old for them to be accepted anyway.
+type nonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time // nonces maps nonces to when they may be forgotten.
+}
+
+func newNonceStore() *nonceStore {
+	return &nonceStore{nonces: make(map[string]time.Time)}
+}
+
+// use records nonce until expires, reporting false if it is recorded already.
+func (s *nonceStore) use(nonce string, expires, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n, exp := range s.nonces {
+		if now.After(exp) {
+			delete(s.nonces, n)
+		}
+	}
+	if _, ok := s.nonces[nonce]; ok {
+		return false
+	}
+	s.nonces[nonce] = expires
+	return true
+}
+
+// requireSignature returns a middleware rejecting requests that are not
+// signed as by [SignRequest], whose timestamp is more than
+// [Options.SignatureMaxAge] away from now, or whose nonce was used already,
+// so a captured request cannot be replayed.
+func (cr *controller) requireSignature() echo.MiddlewareFunc {
+	maxAge := cr.opts.SignatureMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultSignatureMaxAge
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			sig := req.Header.Get(HeaderXSignature)
+			ts := req.Header.Get(HeaderXSignatureTimestamp)
+			nonce := req.Header.Get(HeaderXSignatureNonce)
+			if sig == "" || ts == "" || nonce == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "request signature required")
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			want := signature(cr.opts.SigningKey, req.Method, req.URL.RequestURI(), ts, nonce, body)
+			if !hmac.Equal([]byte(sig), []byte(want)) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid request signature")
+			}
+
+			unix, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid request signature")
+			}
+			signed, now := time.Unix(unix, 0), time.Now()
+			if signed.Before(now.Add(-maxAge)) || signed.After(now.Add(maxAge)) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "request signature expired")
+			}
+			if !cr.nonces.use(nonce, signed.Add(maxAge), now) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "request nonce already used")
+			}
+			return next(c)
+		}
+	}
+}
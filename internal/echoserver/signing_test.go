@@ -0,0 +1,65 @@
+package echoserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestSigning(t *testing.T) {
+	key := []byte("signing-key")
+	_, e := newSQLiteServer(t, WithRequestSigning(key, time.Minute))
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com", "tenant3.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	signed := func(path, nonce string, now time.Time) *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		require.NoError(t, SignRequest(req, key, nonce, now))
+		return req
+	}
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send(signed("/tenants/1?confirm=true", "nonce-1", time.Now()))
+	assert.Equal(t, http.StatusNoContent, rr.Code, "a signed request succeeds: %s", rr.Body.String())
+
+	rr = send(signed("/tenants/1?confirm=true", "nonce-1", time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "a replayed request is rejected")
+	assert.JSONEq(t, `{"message": "request nonce already used"}`, rr.Body.String())
+
+	rr = send(signed("/tenants/2", "nonce-2", time.Now().Add(-2*time.Minute)))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "a stale request is rejected")
+	assert.JSONEq(t, `{"message": "request signature expired"}`, rr.Body.String())
+
+	req := signed("/tenants/2", "nonce-3", time.Now())
+	req.URL.Path = "/tenants/3"
+	req.RequestURI = "/tenants/3"
+	rr = send(req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "a request retargeted at another tenant is rejected")
+	assert.JSONEq(t, `{"message": "invalid request signature"}`, rr.Body.String())
+
+	rr = send(httptest.NewRequest(http.MethodDelete, "/tenants/2", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "an unsigned request is rejected")
+	assert.JSONEq(t, `{"message": "request signature required"}`, rr.Body.String())
+
+	rr = serve(e, http.MethodGet, "/tenants/2", "", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "other routes need no signature")
+}
+
+func TestNonceStoreForgetsExpiredNonces(t *testing.T) {
+	s := newNonceStore()
+	now := time.Now()
+	require.True(t, s.use("a", now.Add(time.Minute), now))
+	require.False(t, s.use("a", now.Add(time.Minute), now))
+	assert.True(t, s.use("b", now.Add(time.Minute), now.Add(2*time.Minute)))
+	assert.NotContains(t, s.nonces, "a")
+}
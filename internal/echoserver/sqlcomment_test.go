@@ -0,0 +1,52 @@
+package echoserver
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+func TestSQLComments(t *testing.T) {
+	db := newSQLiteDB(t)
+	var queries bytes.Buffer
+	db.Logger = logger.New(log.New(&queries, "", 0), logger.Config{LogLevel: logger.Info})
+	_, e := newServer(db, WithSQLComments())
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	send := func(method, path, id string) {
+		t.Helper()
+		queries.Reset()
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = host
+		req.Header.Set(echo.HeaderXRequestID, id)
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		require.Less(t, rr.Code, 300, rr.Body.String())
+	}
+
+	send(http.MethodGet, "/books", "req-1")
+	assert.Contains(t, queries.String(), "/*request_id='req-1',tenant='tenant1'*/ SELECT")
+
+	send(http.MethodDelete, "/books/1", "req 2")
+	assert.Contains(t, queries.String(), "/*request_id='req%202',tenant='tenant1'*/ UPDATE", "soft deletes are tagged")
+	assert.NotContains(t, queries.String(), "req 2", "values are encoded")
+}
+
+func TestSQLComment(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, sqlComment(ctx), "untagged contexts add no comment")
+	ctx = context.WithValue(ctx, requestIDContextKey{}, "a*/b?'c")
+	assert.Equal(t, `/*request_id='a%2A%2Fb%3F%27c'*/`, sqlComment(ctx), "values cannot close the comment or add placeholders")
+}
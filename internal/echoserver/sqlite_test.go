@@ -0,0 +1,274 @@
+package echoserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/initdb"
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSQLiteDB returns a database using the dev-only SQLite driver, with the
+// example models registered and the shared models migrated.
+func newSQLiteDB(t *testing.T) *multitenancy.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, cleanup, err := initdb.Connect(ctx, "sqlite")
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	require.NoError(t, db.RegisterModels(ctx, &models.Tenant{}, &models.BookCounter{}, &models.Book{}, &models.Tag{}))
+	require.NoError(t, db.MigrateSharedModels(ctx))
+	return db
+}
+
+// newSQLiteServer returns a controller backed by [newSQLiteDB].
+func newSQLiteServer(t *testing.T, opts ...Option) (*controller, *echo.Echo) {
+	t.Helper()
+	return newServer(newSQLiteDB(t), opts...)
+}
+
+func newServer(db *multitenancy.DB, opts ...Option) (*controller, *echo.Echo) {
+	cr := &controller{db: db}
+	for _, opt := range opts {
+		opt(&cr.opts)
+	}
+	e := echo.New()
+	cr.init(e)
+	return cr, e
+}
+
+// serve sends a request to e, as the tenant of host if it is not empty.
+func serve(e *echo.Echo, method, path, host, body string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	if host != "" {
+		req.Host = host
+	}
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	return rr
+}
+
+func decode[T any](t *testing.T, rr *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &v), rr.Body.String())
+	return v
+}
+
+func TestSQLiteBookCRUD(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host1, host2 = "tenant1.example.com", "tenant2.example.com"
+
+	for _, domain := range []string{host1, host2} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+domain+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	rr := serve(e, http.MethodPost, "/books", host1, `{"name": "tenant1 - Book 1", "author": "Author 1"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	book := decode[models.BookResponse](t, rr)
+	assert.Equal(t, "tenant1 - Book 1", book.Name)
+	rr = serve(e, http.MethodPost, "/books", host2, `{"name": "tenant2 - Book 1", "author": "Author 1"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	list := func(host string) []models.BookResponse {
+		rr := serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		return decode[[]models.BookResponse](t, rr)
+	}
+	assert.Equal(t, []models.BookResponse{{ID: book.ID, Name: "tenant1 - Book 1", Author: "Author 1", Version: 1}}, list(host1))
+	assert.Equal(t, []models.BookResponse{{ID: 1, Name: "tenant2 - Book 1", Author: "Author 1", Version: 1}}, list(host2), "tenants are isolated")
+
+	rr = serve(e, http.MethodPut, "/books/1", host1, `{"name": "tenant1 - Book 1 - Updated", "version": 1}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "tenant1 - Book 1 - Updated", list(host1)[0].Name)
+	assert.Equal(t, "tenant2 - Book 1", list(host2)[0].Name, "updates do not leak across tenants")
+
+	rr = serve(e, http.MethodDelete, "/books/1", host1, "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodDelete, "/books/1", host1, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "deleted books are gone")
+	rr = serve(e, http.MethodPut, "/books/1", host2, `{"name": "tenant2 - Book 1 - Updated", "version": 1}`)
+	assert.Equal(t, http.StatusOK, rr.Code, "deletes do not leak across tenants")
+
+	rr = serve(e, http.MethodDelete, "/tenants/2?confirm=true", "", "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodGet, "/books", host2, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "offboarded tenants are not served")
+}
+
+func TestConcurrentTenantWrites(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	hosts := map[string]string{"tenant1": "tenant1.example.com", "tenant2": "tenant2.example.com"}
+	for _, host := range hosts {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	const writes = 25
+	var wg sync.WaitGroup
+	for tenant, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range writes {
+				rr := serve(e, http.MethodPost, "/books", host, fmt.Sprintf(`{"name": "%s - Book %d", "author": "Author %d"}`, tenant, i, i))
+				if !assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String()) {
+					return
+				}
+				book := decode[models.BookResponse](t, rr)
+				rr = serve(e, http.MethodPut, fmt.Sprintf("/books/%d", book.ID), host,
+					fmt.Sprintf(`{"name": "%s - Book %d - Updated", "version": %d}`, tenant, i, book.Version))
+				if !assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String()) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for tenant, host := range hosts {
+		rr := serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		books := decode[[]models.BookResponse](t, rr)
+		assert.Len(t, books, writes, tenant)
+		for _, book := range books {
+			assert.Regexp(t, "^"+tenant+" - Book [0-9]+ - Updated$", book.Name, "writes stay within their tenant")
+		}
+	}
+}
+
+func TestStaleBookUpdate(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	read := decode[models.BookResponse](t, rr)
+	require.Equal(t, uint(1), read.Version)
+
+	// Two clients read the book at the same version; the first to write wins.
+	rr = serve(e, http.MethodPut, "/books/1", host, fmt.Sprintf(`{"name": "Dune Messiah", "version": %d}`, read.Version))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPut, "/books/1", host, fmt.Sprintf(`{"name": "Children of Dune", "version": %d}`, read.Version))
+	require.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+	assert.Equal(t, "book was modified concurrently, fetch it and retry", decode[models.ErrorResponse](t, rr).Message)
+
+	rr = serve(e, http.MethodGet, "/books/1", host, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	read = decode[models.BookResponse](t, rr)
+	assert.Equal(t, models.BookResponse{ID: 1, Name: "Dune Messiah", Author: "Frank Herbert", Version: 2}, read,
+		"the stale update is not applied")
+
+	rr = serve(e, http.MethodPut, "/books/1", host, fmt.Sprintf(`{"name": "Children of Dune", "version": %d}`, read.Version))
+	require.Equal(t, http.StatusOK, rr.Code, "a retry from the current version applies: %s", rr.Body.String())
+	rr = serve(e, http.MethodGet, "/books/1", host, "")
+	assert.Equal(t, models.BookResponse{ID: 1, Name: "Children of Dune", Author: "Frank Herbert", Version: 3},
+		decode[models.BookResponse](t, rr))
+}
+
+func TestInvalidBookID(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	for _, id := range []string{"abc", "0", "-1", "1%20OR%201=1"} {
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			var body string
+			if method == http.MethodPut {
+				body = `{"name": "Dune Messiah", "version": 1}`
+			}
+			rr = serve(e, method, "/books/"+id, host, body)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, "%s /books/%s: %s", method, id, rr.Body.String())
+		}
+	}
+	rr = serve(e, http.MethodGet, "/books/1", host, "")
+	assert.Equal(t, models.BookResponse{ID: 1, Name: "Dune", Author: "Frank Herbert", Version: 1}, decode[models.BookResponse](t, rr),
+		"the book is untouched")
+}
+
+func TestCreateBookIgnoresModelFields(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	rr = serve(e, http.MethodPost, "/books", host, `{"id": 99, "version": 7, "createdAt": "2000-01-01T00:00:00Z",
+		"tenantSchema": "tenant2", "name": "Dune", "author": "Frank Herbert", "tags": [{"id": 42, "bookId": 7, "name": "sci-fi"}]}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, models.BookResponse{ID: 1, Name: "Dune", Author: "Frank Herbert", Version: 1}, decode[models.BookResponse](t, rr))
+
+	var tags []models.Tag
+	require.NoError(t, cr.withTenantTx(context.Background(), "tenant1", func(tx *multitenancy.DB) error {
+		return tx.Find(&tags).Error
+	}))
+	require.Len(t, tags, 1)
+	assert.Equal(t, uint(1), tags[0].ID, "the tag's ID is not the client's")
+	assert.Equal(t, uint(1), tags[0].BookID, "the tag belongs to the new book")
+	assert.Equal(t, "sci-fi", tags[0].Name)
+}
+
+func TestBooksShape(t *testing.T) {
+	_, e := newSQLiteServer(t, WithTierFields("free", "name"))
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant2.example.com", "tier": "free"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		for i := 1; i <= 3; i++ {
+			rr := serve(e, http.MethodPost, "/books", host, fmt.Sprintf(`{"name": "Book %d", "author": "Author %d"}`, i, i))
+			require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = serve(e, http.MethodGet, "/books", "tenant1.example.com", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	books := decode[[]models.BookResponse](t, rr)
+	require.Len(t, books, 3, "an array by default")
+
+	rr = serve(e, http.MethodGet, "/books?shape=map", "tenant1.example.com", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	byID := decode[map[string]models.BookResponse](t, rr)
+	want := make(map[string]models.BookResponse)
+	for _, b := range books {
+		want[fmt.Sprint(b.ID)] = b
+	}
+	assert.Equal(t, want, byID, "the map holds the same books, keyed by ID")
+
+	rr = serve(e, http.MethodGet, "/books?shape=map&limit=2", "tenant1.example.com", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Len(t, decode[map[string]models.BookResponse](t, rr), 2)
+	assert.NotEmpty(t, rr.Header().Get(HeaderXNextCursor), "maps are paged like arrays")
+
+	rr = serve(e, http.MethodGet, "/books?shape=map", "tenant2.example.com", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"1": {"name": "Book 1"}, "2": {"name": "Book 2"}, "3": {"name": "Book 3"}}`, rr.Body.String(),
+		"tier fields are projected")
+
+	for _, query := range []string{"?shape=tree", "?shape=map&view=flat"} {
+		rr := serve(e, http.MethodGet, "/books"+query, "tenant1.example.com", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
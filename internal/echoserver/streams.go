@@ -0,0 +1,68 @@
+package echoserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// streamTracker tracks the long-lived streaming responses, so that shutdown
+// can ask them to end and wait for them before draining the other requests.
+type streamTracker struct {
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	active int
+	closed bool // closed is set once shutdown begins: no stream may begin then.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newStreamTracker() *streamTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &streamTracker{ctx: ctx, cancel: cancel}
+}
+
+// begin registers a stream of the request of ctx. The returned context is
+// canceled when ctx is or when shutdown begins; the stream must then end,
+// and call end once it has. Once shutdown has begun, streams are refused
+// with 503.
+func (t *streamTracker) begin(ctx context.Context) (_ context.Context, end func(), err error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, nil, echo.NewHTTPError(http.StatusServiceUnavailable, "server is shutting down")
+	}
+	// Added under the lock, so that it happens before closeAll waits.
+	t.wg.Add(1)
+	t.active++
+	t.mu.Unlock()
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(t.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		t.mu.Lock()
+		t.active--
+		t.mu.Unlock()
+		t.wg.Done()
+	}, nil
+}
+
+// closeAll asks every stream to end and waits for them to, or for ctx to be
+// done.
+func (t *streamTracker) closeAll(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	t.cancel()
+	return wait(ctx, &t.wg)
+}
+
+// open returns the number of streams that have not ended.
+func (t *streamTracker) open() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
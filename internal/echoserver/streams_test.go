@@ -0,0 +1,100 @@
+package echoserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveStreams serves e on a local listener, with a streaming route that
+// writes a line, then blocks until its stream is asked to end, or, if
+// stubborn, until release is closed.
+func serveStreams(t *testing.T, cr *controller, e *echo.Echo, release <-chan struct{}) (*http.Server, string) {
+	e.GET("/admin/stream", func(c echo.Context) error {
+		ctx, end, err := cr.streams.begin(c.Request().Context())
+		if err != nil {
+			return err
+		}
+		defer end()
+		c.Response().WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(c.Response(), "hello\n")
+		c.Response().Flush()
+		if c.QueryParam("stubborn") != "" {
+			<-release
+		} else {
+			<-ctx.Done()
+		}
+		return nil
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: e}
+	go func() { _ = srv.Serve(ln) }()
+	return srv, "http://" + ln.Addr().String() + "/admin/stream"
+}
+
+// openStream opens the stream at url and reads its first line.
+func openStream(t *testing.T, url string) io.ReadCloser {
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", line)
+	return res.Body
+}
+
+func TestShutdownClosesStreams(t *testing.T) {
+	cr, e := newSQLiteServer(t)
+	srv, url := serveStreams(t, cr, e, nil)
+	body := openStream(t, url)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, cr.shutdown(ctx, srv))
+	assert.Less(t, time.Since(start), time.Second, "streams end as soon as asked")
+	_, err := io.ReadAll(body)
+	assert.NoError(t, err, "the stream ends cleanly")
+}
+
+func TestShutdownForciblyClosesStubbornStreams(t *testing.T) {
+	var logs bytes.Buffer
+	cr, e := newSQLiteServer(t,
+		WithStreamDrainTimeout(20*time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	release := make(chan struct{})
+	defer close(release)
+	srv, url := serveStreams(t, cr, e, release)
+	body := openStream(t, url+"?stubborn=1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := cr.shutdown(ctx, srv)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "close streams")
+	assert.Contains(t, logs.String(), "Streams did not end in time")
+	assert.Contains(t, logs.String(), `msg="Closing connections forcibly" streams=1`)
+	_, err = io.ReadAll(body)
+	assert.Error(t, err, "the connection is cut")
+}
+
+func TestStreamsRefusedAfterShutdown(t *testing.T) {
+	tracker := newStreamTracker()
+	require.NoError(t, tracker.closeAll(context.Background()))
+	_, _, err := tracker.begin(context.Background())
+	var he *echo.HTTPError
+	require.ErrorAs(t, err, &he)
+	assert.Equal(t, http.StatusServiceUnavailable, he.Code)
+	assert.Zero(t, tracker.open())
+}
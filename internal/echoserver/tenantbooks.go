@@ -0,0 +1,28 @@
+package echoserver
+
+import (
+	"net/http"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// getTenantBooksHandler lists the books of the tenant with the given ID, for
+// operators. No tenant is resolved from the request, so the schema is taken
+// from the tenant record alone; every field is returned whatever the
+// tenant's tier.
+func (cr *controller) getTenantBooksHandler(c echo.Context) error {
+	id, err := tenantIDParam(c)
+	if err != nil {
+		return err
+	}
+	var tenant models.Tenant
+	if err = cr.db.WithContext(c.Request().Context()).First(&tenant, id).Error; err != nil {
+		return err
+	}
+	books, err := cr.listBooks(c, tenant.SchemaName)
+	if err != nil {
+		return err
+	}
+	return respond(c, http.StatusOK, books)
+}
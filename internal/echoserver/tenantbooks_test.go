@@ -0,0 +1,66 @@
+package echoserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantBooks(t *testing.T) {
+	const token = "secret"
+	_, e := newSQLiteServer(t, WithAdminToken(token), WithTierFields("free", "name"))
+	for i, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`", "tier": "free"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		for j := 1; j <= 3; j++ {
+			body := fmt.Sprintf(`{"name": "tenant%d book %d", "author": "Author"}`, i+1, j)
+			rr := serve(e, http.MethodPost, "/books", host, body)
+			require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		}
+	}
+	rr := serve(e, http.MethodDelete, "/tenants/1?confirm=true", "", "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	get := func(path, auth, host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+auth)
+		}
+		req.Host = host
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = get("/tenants/2/books?limit=2", token, "tenant1.example.com")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []models.BookResponse{
+		{ID: 1, Name: "tenant2 book 1", Author: "Author", Version: 1},
+		{ID: 2, Name: "tenant2 book 2", Author: "Author", Version: 1},
+	}, decode[[]models.BookResponse](t, rr), "the books of the tenant in the path, unprojected, whatever the host")
+	next := rr.Header().Get(HeaderXNextCursor)
+	require.NotEmpty(t, next)
+
+	rr = get("/tenants/2/books?after="+next, token, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []models.BookResponse{{ID: 3, Name: "tenant2 book 3", Author: "Author", Version: 1}}, decode[[]models.BookResponse](t, rr))
+
+	for name, tt := range map[string]struct {
+		path, auth string
+		want       int
+	}{
+		"NoToken":       {"/tenants/2/books", "", http.StatusUnauthorized},
+		"WrongToken":    {"/tenants/2/books", "wrong", http.StatusUnauthorized},
+		"DeletedTenant": {"/tenants/1/books", token, http.StatusNotFound},
+		"UnknownTenant": {"/tenants/9/books", token, http.StatusNotFound},
+		"InvalidID":     {"/tenants/1%20OR%201=1/books", token, http.StatusBadRequest},
+	} {
+		assert.Equal(t, tt.want, get(tt.path, tt.auth, "").Code, name)
+	}
+}
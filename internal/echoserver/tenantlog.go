@@ -0,0 +1,102 @@
+package echoserver
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const defaultSlowQueryThreshold = 200 * time.Millisecond
+
+type tenantContextKey struct{}
+
+// tenantContext returns a middleware adding the resolved tenant to the
+// request context, so code given only the context, such as the query
+// logger, can tell which tenant it is serving.
+func tenantContext() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if tenantID, err := TenantFromContext(c); err == nil {
+				req := c.Request()
+				c.SetRequest(req.WithContext(context.WithValue(req.Context(), tenantContextKey{}, tenantID)))
+			}
+			return next(c)
+		}
+	}
+}
+
+// tenantLogger is a GORM logger routing the query logs of tenants with a
+// dedicated sink to that sink, and everything else to the fallback logger.
+type tenantLogger struct {
+	fallback logger.Interface
+	sinks    map[string]logger.Interface
+}
+
+var _ logger.Interface = (*tenantLogger)(nil)
+
+// newTenantLogger returns a logger writing slow queries and errors of each
+// tenant in sinks to its writer.
+func newTenantLogger(fallback logger.Interface, sinks map[string]io.Writer, slowThreshold time.Duration) *tenantLogger {
+	l := &tenantLogger{fallback: fallback, sinks: make(map[string]logger.Interface, len(sinks))}
+	for tenant, w := range sinks {
+		l.sinks[tenant] = logger.New(log.New(w, "["+tenant+"] ", log.LstdFlags), logger.Config{
+			SlowThreshold:             slowThreshold,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+		})
+	}
+	return l
+}
+
+func (l *tenantLogger) logger(ctx context.Context) logger.Interface {
+	if tenantID, ok := ctx.Value(tenantContextKey{}).(string); ok {
+		if sink, ok := l.sinks[tenantID]; ok {
+			return sink
+		}
+	}
+	return l.fallback
+}
+
+func (l *tenantLogger) LogMode(level logger.LogLevel) logger.Interface {
+	sinks := make(map[string]logger.Interface, len(l.sinks))
+	for tenant, sink := range l.sinks {
+		sinks[tenant] = sink.LogMode(level)
+	}
+	return &tenantLogger{fallback: l.fallback.LogMode(level), sinks: sinks}
+}
+
+func (l *tenantLogger) Info(ctx context.Context, msg string, args ...any) {
+	l.logger(ctx).Info(ctx, msg, args...)
+}
+
+func (l *tenantLogger) Warn(ctx context.Context, msg string, args ...any) {
+	l.logger(ctx).Warn(ctx, msg, args...)
+}
+
+func (l *tenantLogger) Error(ctx context.Context, msg string, args ...any) {
+	l.logger(ctx).Error(ctx, msg, args...)
+}
+
+func (l *tenantLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	l.logger(ctx).Trace(ctx, begin, fc, err)
+}
+
+// useTenantLogSinks routes the query logs of the configured tenants to their
+// sinks.
+func (cr *controller) useTenantLogSinks() {
+	threshold := cr.opts.SlowQueryThreshold
+	if threshold <= 0 {
+		threshold = defaultSlowQueryThreshold
+	}
+	if _, ok := cr.db.Logger.(*tenantLogger); ok {
+		return // already routed by a previous start
+	}
+	cr.db = cr.db.Session(&gorm.Session{
+		Logger: newTenantLogger(cr.db.Logger, cr.opts.TenantLogSinks, threshold),
+	})
+}
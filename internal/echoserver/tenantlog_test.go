@@ -0,0 +1,36 @@
+package echoserver
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+func TestTenantLogSinks(t *testing.T) {
+	db := newSQLiteDB(t)
+	var global, premium bytes.Buffer
+	db.Logger = logger.New(log.New(&global, "", 0), logger.Config{SlowThreshold: time.Nanosecond, LogLevel: logger.Warn})
+	_, e := newServer(db, WithTenantLogSink("tenant1", &premium), WithSlowQueryThreshold(time.Nanosecond))
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	global.Reset()
+
+	for _, host := range []string{"tenant1.example.com", "tenant2.example.com"} {
+		rr := serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	assert.Contains(t, premium.String(), "[tenant1] ")
+	assert.Contains(t, premium.String(), "`tenant1`.`books`", "flagged tenant's queries reach its sink")
+	assert.NotContains(t, premium.String(), "tenant2")
+	assert.Contains(t, global.String(), "`tenant2`.`books`", "other tenants log to the default logger")
+	assert.NotContains(t, global.String(), "`tenant1`.`books`")
+}
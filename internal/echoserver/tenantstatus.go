@@ -0,0 +1,46 @@
+package echoserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+func (cr *controller) suspendTenantHandler(c echo.Context) error {
+	return cr.setTenantStatus(c, models.TenantSuspended)
+}
+
+func (cr *controller) activateTenantHandler(c echo.Context) error {
+	return cr.setTenantStatus(c, models.TenantActive)
+}
+
+// setTenantStatus flips the status of the tenant between active and
+// suspended. A pending tenant has no schema to serve, so it is only
+// activated by migrating it.
+func (cr *controller) setTenantStatus(c echo.Context, status string) error {
+	id, err := tenantIDParam(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	tenant := &models.Tenant{}
+	if err = cr.db.WithContext(ctx).First(tenant, id).Error; err != nil {
+		return err
+	}
+	if tenant.Status == models.TenantPending {
+		return echo.NewHTTPError(http.StatusConflict,
+			fmt.Sprintf("tenant %q is pending, migrate it to activate it", tenant.SchemaName))
+	}
+	if tenant.Status != status {
+		if err := cr.db.WithContext(ctx).Model(tenant).Update("status", status).Error; err != nil {
+			return err
+		}
+		cr.audit(ctx, tenant.SchemaName, AuditUpdate, AuditTenant, strconv.FormatUint(uint64(tenant.ID), 10))
+	}
+	// Other servers notice the change once their cached status expires.
+	cr.tenants.SetStatus(tenant.SchemaName, status)
+	return respond(c, http.StatusOK, tenantResponse(tenant))
+}
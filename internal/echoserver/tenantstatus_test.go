@@ -0,0 +1,89 @@
+package echoserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantStatus(t *testing.T) {
+	const (
+		token = "admin-secret"
+		host  = "tenant1.example.com"
+	)
+	cr, e := newSQLiteServer(t, WithAdminToken(token))
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	t.Run("Active", func(t *testing.T) {
+		rr := serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		misses := cr.tenants.Stats().Misses
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, misses, cr.tenants.Stats().Misses, "the status is cached")
+	})
+
+	t.Run("Suspended", func(t *testing.T) {
+		rr := serveAdmin(e, http.MethodPost, "/tenants/1/suspend", token)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, models.TenantSuspended, decode[models.TenantResponse](t, rr).Status)
+
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusForbidden, rr.Code, rr.Body.String())
+		assert.Equal(t, "tenant is suspended", decode[models.ErrorResponse](t, rr).Message)
+
+		cr.tenants.Clear()
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		assert.Equal(t, http.StatusForbidden, rr.Code, "the status is stored: %s", rr.Body.String())
+	})
+
+	t.Run("Activated", func(t *testing.T) {
+		rr := serveAdmin(e, http.MethodPost, "/tenants/1/activate", token)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, models.TenantActive, decode[models.TenantResponse](t, rr).Status)
+
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		_, e := newSQLiteServer(t, WithAdminToken(token), WithAutoMigrate(false))
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusLocked, rr.Code, rr.Body.String())
+		assert.Equal(t, "tenant is pending migration", decode[models.ErrorResponse](t, rr).Message)
+		for _, action := range []string{"suspend", "activate"} {
+			rr = serveAdmin(e, http.MethodPost, "/tenants/1/"+action, token)
+			assert.Equal(t, http.StatusConflict, rr.Code, "%s: %s", action, rr.Body.String())
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		rr := serveAdmin(e, http.MethodPost, "/tenants/9/suspend", token)
+		assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodGet, "/books", "tenant9.example.com", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		for _, action := range []string{"suspend", "activate"} {
+			rr := serveAdmin(e, http.MethodPost, "/tenants/1%20OR%201=1/"+action, token)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, "%s: %s", action, rr.Body.String())
+		}
+		rr := serve(e, http.MethodDelete, "/tenants/abc?confirm=true", "", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		assert.Equal(t, http.StatusOK, rr.Code, "the tenant is untouched: %s", rr.Body.String())
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		rr := serveAdmin(e, http.MethodPost, "/tenants/1/suspend", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
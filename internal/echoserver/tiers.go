@@ -0,0 +1,84 @@
+package echoserver
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// tierFields returns the fields the tenant's tier is entitled to, or nil if
+// it is entitled to all of them.
+func (cr *controller) tierFields(ctx context.Context, tenantID string) ([]string, error) {
+	if len(cr.opts.TierFields) == 0 {
+		return nil, nil // skip the lookup when no tier is restricted
+	}
+	var tiers []string
+	if err := cr.db.WithContext(ctx).Model(&models.Tenant{}).
+		Where("schema_name = ?", tenantID).Limit(1).
+		Pluck("tier", &tiers).Error; err != nil {
+		return nil, err
+	}
+	if len(tiers) == 0 {
+		return nil, nil
+	}
+	return cr.opts.TierFields[tiers[0]], nil
+}
+
+// respondProjected writes v like [respond], keeping only the fields the
+// tenant's tier is entitled to.
+func (cr *controller) respondProjected(c echo.Context, tenantID string, status int, v any) error {
+	fields, err := cr.tierFields(c.Request().Context(), tenantID)
+	if err != nil {
+		return err
+	}
+	if fields != nil {
+		v = project(reflect.ValueOf(v), fields)
+	}
+	return respond(c, status, v)
+}
+
+// project converts the struct, or slice or map of structs, v into maps
+// holding only the given fields, identified by their JSON names. Other values
+// are returned unchanged.
+func project(v reflect.Value, fields []string) any {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = project(v.Index(i), fields)
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			out[iter.Key().String()] = project(iter.Value(), fields)
+		}
+		return out
+	case reflect.Struct:
+		out := make(map[string]any, len(fields))
+		for i := range v.NumField() {
+			name, opts, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" || !slices.Contains(fields, name) {
+				continue
+			}
+			f := v.Field(i)
+			if strings.Contains(opts, "omitempty") && f.IsZero() {
+				continue
+			}
+			out[name] = f.Interface()
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
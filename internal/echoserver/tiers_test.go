@@ -0,0 +1,52 @@
+package echoserver
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTierFields(t *testing.T) {
+	_, e := newSQLiteServer(t, WithTierFields("free", "id", "name", "bookId", "tag"))
+	const (
+		free    = "free.example.com"
+		premium = "premium.example.com"
+	)
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+free+`", "tier": "free"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, "free", decode[models.TenantResponse](t, rr).Tier)
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+premium+`", "tier": "premium"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	const book = `{"name": "Dune", "author": "Frank Herbert", "isbn": "9780306406157"}`
+	for _, host := range []string{free, premium} {
+		rr = serve(e, http.MethodPost, "/books", host, book)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		path, host, want string
+	}{
+		{"/books", free, `[{"id": 1, "name": "Dune"}]`},
+		{"/books/1", free, `{"id": 1, "name": "Dune"}`},
+		{"/books?view=flat", free, `[{"bookId": 1, "name": "Dune"}]`},
+		{"/books", premium, `[{"id": 1, "name": "Dune", "author": "Frank Herbert", "isbn": "9780306406157", "version": 1}]`},
+		{"/books/1", premium, `{"id": 1, "name": "Dune", "author": "Frank Herbert", "isbn": "9780306406157", "version": 1}`},
+	}
+	for _, tt := range tests {
+		rr = serve(e, http.MethodGet, tt.path, tt.host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.JSONEq(t, tt.want, rr.Body.String(), "%s of %s", tt.path, tt.host)
+	}
+}
+
+func TestProject(t *testing.T) {
+	books := []models.BookResponse{{ID: 1, Name: "Dune", Author: "Frank Herbert"}}
+	assert.Equal(t, []any{map[string]any{"id": uint(1), "author": "Frank Herbert"}},
+		project(reflect.ValueOf(books), []string{"id", "author", "isbn"}), "empty omitempty fields are dropped")
+	assert.Equal(t, map[string]any{}, project(reflect.ValueOf(&books[0]), []string{}), "empty allowlist returns no fields")
+	assert.Equal(t, 42, project(reflect.ValueOf(42), []string{"id"}))
+}
@@ -0,0 +1,32 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// timeout returns a middleware bounding each request to d, by handing the
+// handler a request context that is canceled once d has passed. A handler
+// still failing when the deadline is exceeded, typically because its
+// queries were canceled, fails with 504. Handlers must honor their context
+// to be bounded, so the middleware is not applied to streamed routes, which
+// outlive any request deadline by design.
+func timeout(d time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+			c.SetRequest(req.WithContext(ctx))
+			err := next(c)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && req.Context().Err() == nil {
+				return echo.NewHTTPError(http.StatusGatewayTimeout, "request timed out")
+			}
+			return err
+		}
+	}
+}
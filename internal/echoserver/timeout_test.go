@@ -0,0 +1,70 @@
+package echoserver
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	e := echo.New()
+	e.GET("/slow", func(c echo.Context) error {
+		select {
+		case <-c.Request().Context().Done():
+			return c.Request().Context().Err()
+		case <-time.After(5 * time.Second):
+			return c.NoContent(http.StatusNoContent)
+		}
+	}, timeout(20*time.Millisecond))
+	e.GET("/fast", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, timeout(time.Second))
+
+	start := time.Now()
+	rr := serve(e, http.MethodGet, "/slow", "", "")
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	assert.Less(t, time.Since(start), time.Second, "the handler is cut short")
+	assert.Equal(t, http.StatusNoContent, serve(e, http.MethodGet, "/fast", "", "").Code)
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	cr, e := newSQLiteServer(t, WithHandlerTimeout(50*time.Millisecond))
+
+	t.Run("SlowHandler", func(t *testing.T) {
+		cr.migrations = slowMigrator{cr.db}
+		defer func() { cr.migrations = nil }()
+		rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "tenant1.example.com"}`)
+		require.Equal(t, http.StatusGatewayTimeout, rr.Code, rr.Body.String())
+		assert.Equal(t, "request timed out", decode[models.ErrorResponse](t, rr).Message)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/tenants/1", "", "").Code, "the tenant is removed")
+	})
+
+	t.Run("StreamsAreExempt", func(t *testing.T) {
+		gate := make(chan struct{})
+		cr.migrations = gatedMigrator{tenantMigrator: cr.db, gate: gate}
+		defer func() { cr.migrations = nil }()
+		rr := serve(e, http.MethodPost, "/tenants?async=true", "", `{"domainUrl": "tenant1.example.com"}`)
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		job := decode[models.JobResponse](t, rr)
+
+		srv := httptest.NewServer(e)
+		defer srv.Close()
+		res, err := http.Get(srv.URL + "/tenants/jobs/" + job.ID + "/logs")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		r := bufio.NewReader(res.Body)
+		assert.Equal(t, jobEventStep, readEvent(t, r).event)
+
+		time.Sleep(100 * time.Millisecond) // past the handler timeout
+		close(gate)
+		assert.Equal(t, jobEventDone, readEvent(t, r).event, "the stream outlives the timeout")
+	})
+}
@@ -0,0 +1,154 @@
+package echoserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
+)
+
+// tracerName names the tracer of the server's spans.
+const tracerName = "github.com/bartventer/gorm-multitenancy/examples/v8/internal/echoserver"
+
+const (
+	traceStartCallback = "echoserver:trace_start"
+	traceEndCallback   = "echoserver:trace_end"
+	traceSpanKey       = "echoserver:span"
+)
+
+// tenantAttribute is the span attribute naming the tenant a span is for.
+const tenantAttribute = attribute.Key("tenant.id")
+
+// tracer returns the tracer of [Options.TracerProvider], or a no-op tracer.
+func (cr *controller) tracer() trace.Tracer {
+	tp := cr.opts.TracerProvider
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(tracerName, trace.WithSchemaURL(semconv.SchemaURL))
+}
+
+// startSpan starts a span named name, a child of the span of ctx if any.
+func (cr *controller) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return cr.tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it failed with err if err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceRequests returns a middleware starting a server span per request,
+// continuing the trace of its traceparent header if any. The span is put in
+// the request context, so the work done for the request, database calls
+// included, is traced as its children. Errors are written here rather than
+// by the logger, so that the span gets the status sent. It must run outside
+// the panic recovery, which turns panics into 500s.
+func (cr *controller) traceRequests() echo.MiddlewareFunc {
+	tracer := cr.tracer()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := propagation.TraceContext{}.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			name := req.Method
+			if route := c.Path(); route != "" {
+				name += " " + route
+			}
+			ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.HTTPRoute(c.Path()),
+				semconv.URLPath(req.URL.Path),
+			))
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				span.RecordError(err)
+				c.Error(err)
+			}
+			if tenantID, tenantErr := TenantFromContext(c); tenantErr == nil {
+				span.SetAttributes(tenantAttribute.String(tenantID))
+			}
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			// Client errors are the client's; only server errors fail the span.
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			return err
+		}
+	}
+}
+
+// EnableTracing traces every statement run by db as a client span, a child
+// of the span of the statement's context, with the provider of that span.
+// Statements run outside a span are not traced. It is idempotent.
+func EnableTracing(db *gorm.DB) error {
+	cb := db.Callback()
+	if cb.Query().Get(traceStartCallback) != nil {
+		return nil
+	}
+	return errors.Join(
+		cb.Create().Before("*").Register(traceStartCallback, startDBSpan("create")),
+		cb.Create().After("*").Register(traceEndCallback, endDBSpan),
+		cb.Query().Before("*").Register(traceStartCallback, startDBSpan("query")),
+		cb.Query().After("*").Register(traceEndCallback, endDBSpan),
+		cb.Update().Before("*").Register(traceStartCallback, startDBSpan("update")),
+		cb.Update().After("*").Register(traceEndCallback, endDBSpan),
+		cb.Delete().Before("*").Register(traceStartCallback, startDBSpan("delete")),
+		cb.Delete().After("*").Register(traceEndCallback, endDBSpan),
+		cb.Row().Before("*").Register(traceStartCallback, startDBSpan("row")),
+		cb.Row().After("*").Register(traceEndCallback, endDBSpan),
+		cb.Raw().Before("*").Register(traceStartCallback, startDBSpan("raw")),
+		cb.Raw().After("*").Register(traceEndCallback, endDBSpan),
+	)
+}
+
+// startDBSpan returns a callback starting the span of a statement of the
+// given operation.
+func startDBSpan(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		parent := trace.SpanFromContext(stmt.Context)
+		if !parent.SpanContext().IsValid() {
+			return
+		}
+		ctx, span := parent.TracerProvider().Tracer(tracerName, trace.WithSchemaURL(semconv.SchemaURL)).
+			Start(stmt.Context, "gorm."+op, trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(semconv.DBOperationName(op)))
+		stmt.Context = ctx
+		db.InstanceSet(traceSpanKey, span)
+	}
+}
+
+// endDBSpan ends the span of the statement, if it is traced.
+func endDBSpan(db *gorm.DB) {
+	v, _ := db.InstanceGet(traceSpanKey)
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	if stmt.Table != "" {
+		span.SetAttributes(semconv.DBCollectionName(stmt.Table))
+	}
+	span.SetAttributes(semconv.DBQueryText(stmt.SQL.String()))
+	var err error
+	if !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		err = db.Error
+	}
+	endSpan(span, err)
+}
@@ -0,0 +1,136 @@
+package echoserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+func TestTracing(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	cr, e := newSQLiteServer(t, WithTracerProvider(tp))
+	const host = "tenant1.example.com"
+
+	// spans returns the spans ended since the last call.
+	var seen int
+	spans := func() []sdktrace.ReadOnlySpan {
+		ended := sr.Ended()
+		defer func() { seen = len(ended) }()
+		return ended[seen:]
+	}
+	named := func(spans []sdktrace.ReadOnlySpan, name string) []sdktrace.ReadOnlySpan {
+		return slices.DeleteFunc(slices.Clone(spans), func(s sdktrace.ReadOnlySpan) bool { return s.Name() != name })
+	}
+	attrs := func(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range s.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	ended := spans()
+	server := named(ended, "POST /tenants")
+	require.Len(t, server, 1)
+	migrations := named(ended, "migrate tenant")
+	require.Len(t, migrations, 1)
+	assert.Equal(t, server[0].SpanContext().SpanID(), migrations[0].Parent().SpanID())
+	assert.Equal(t, "tenant1", attrs(migrations[0])[tenantAttribute].AsString())
+
+	t.Run("Request", func(t *testing.T) {
+		rr := serve(e, http.MethodPost, "/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		ended := spans()
+		server := named(ended, "POST /books")
+		require.Len(t, server, 1, "one span per request")
+		span := server[0]
+		assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+		a := attrs(span)
+		assert.Equal(t, "POST", a["http.request.method"].AsString())
+		assert.Equal(t, "/books", a["http.route"].AsString())
+		assert.Equal(t, int64(http.StatusCreated), a["http.response.status_code"].AsInt64())
+		assert.Equal(t, "tenant1", a[tenantAttribute].AsString())
+		assert.Equal(t, codes.Unset, span.Status().Code)
+
+		resolve := named(ended, "resolve tenant")
+		require.Len(t, resolve, 1)
+		assert.Equal(t, span.SpanContext().SpanID(), resolve[0].Parent().SpanID())
+		creates := named(ended, "gorm.create")
+		require.NotEmpty(t, creates, "database calls are traced")
+		for _, s := range creates {
+			assert.Equal(t, span.SpanContext().TraceID(), s.SpanContext().TraceID())
+			assert.Equal(t, trace.SpanKindClient, s.SpanKind())
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		rr := serve(e, http.MethodGet, "/books/9", host, "")
+		require.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+		server := named(spans(), "GET /books/:id")
+		require.Len(t, server, 1)
+		span := server[0]
+		assert.Equal(t, int64(http.StatusNotFound), attrs(span)["http.response.status_code"].AsInt64())
+		assert.Equal(t, codes.Unset, span.Status().Code, "client errors do not fail the span")
+		require.NotEmpty(t, span.Events())
+		assert.Equal(t, "exception", span.Events()[0].Name, "the error is recorded")
+	})
+
+	t.Run("Propagation", func(t *testing.T) {
+		const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		req.Host = host
+		req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		server := named(spans(), "GET /books")
+		require.Len(t, server, 1)
+		assert.Equal(t, traceID, server[0].SpanContext().TraceID().String(), "the caller's trace is continued")
+	})
+
+	t.Run("DeleteTenant", func(t *testing.T) {
+		rr := serve(e, http.MethodDelete, "/tenants/1", "", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		ended := spans()
+		server := named(ended, "DELETE /tenants/:id")
+		require.Len(t, server, 1)
+		lookups := slices.DeleteFunc(named(ended, "gorm.query"), func(s sdktrace.ReadOnlySpan) bool {
+			return s.Parent().SpanID() != server[0].SpanContext().SpanID() ||
+				attrs(s)["db.collection.name"].AsString() != "tenants"
+		})
+		assert.NotEmpty(t, lookups, "the tenant lookup runs in the request's context")
+	})
+
+	t.Run("ServerError", func(t *testing.T) {
+		require.NoError(t, cr.db.Callback().Query().Before("gorm:query").Register("test:fail", func(db *gorm.DB) {
+			if db.Statement.Table == models.TableNameBook {
+				_ = db.AddError(errors.New("disk failure"))
+			}
+		}))
+		rr := serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusInternalServerError, rr.Code, rr.Body.String())
+		ended := spans()
+		server := named(ended, "GET /books")
+		require.Len(t, server, 1)
+		assert.Equal(t, codes.Error, server[0].Status().Code)
+		queries := slices.DeleteFunc(named(ended, "gorm.query"), func(s sdktrace.ReadOnlySpan) bool {
+			return attrs(s)["db.collection.name"].AsString() != models.TableNameBook
+		})
+		require.Len(t, queries, 1)
+		assert.Equal(t, codes.Error, queries[0].Status().Code)
+		assert.Equal(t, "disk failure", queries[0].Status().Description)
+	})
+}
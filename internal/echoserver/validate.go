@@ -0,0 +1,75 @@
+package echoserver
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// requestValidator is the [echo.Validator] checking request bodies against
+// their `validate` struct tags.
+type requestValidator struct {
+	v *validator.Validate
+}
+
+var _ echo.Validator = (*requestValidator)(nil)
+
+func newRequestValidator() *requestValidator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by the name clients send them as.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return strings.ToLower(f.Name)
+		}
+		return name
+	})
+	_ = v.RegisterValidation("domainurl", func(fl validator.FieldLevel) bool {
+		return isDomainURL(v, fl.Field().String())
+	})
+	return &requestValidator{v: v}
+}
+
+// Validate implements [echo.Validator].
+func (rv *requestValidator) Validate(i any) error {
+	return rv.v.Struct(i)
+}
+
+// isDomainURL reports whether s is a host name, optionally with an http(s)
+// scheme, such as "tenant1.example.com".
+func isDomainURL(v *validator.Validate, s string) bool {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return v.Var(u.Hostname(), "required,fqdn") == nil
+}
+
+// fieldErrors returns the field errors of a failed validation, or nil if err
+// is not one.
+func fieldErrors(err error) []models.FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	fields := make([]models.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, models.FieldError{
+			Field: fe.Field(),
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+		})
+	}
+	return fields
+}
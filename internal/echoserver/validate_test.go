@@ -0,0 +1,127 @@
+package echoserver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bartventer/gorm-multitenancy/examples/v8/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	rr = serve(e, http.MethodPost, "/books", host, `{"name": "Dune", "author": "Frank Herbert"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	long := strings.Repeat("a", 256)
+	tests := []struct {
+		name         string
+		method, path string
+		host, body   string
+		want         []models.FieldError
+	}{
+		{
+			name:   "TenantMissingDomain",
+			method: http.MethodPost, path: "/tenants",
+			body: `{}`,
+			want: []models.FieldError{{Field: "domainUrl", Rule: "required"}},
+		},
+		{
+			name:   "TenantInvalidDomain",
+			method: http.MethodPost, path: "/tenants",
+			body: `{"domainUrl": "ftp://tenant2.example.com"}`,
+			want: []models.FieldError{{Field: "domainUrl", Rule: "domainurl"}},
+		},
+		{
+			name:   "TenantMalformedDomain",
+			method: http.MethodPost, path: "/tenants",
+			body: `{"domainUrl": "tenant2 example.com"}`,
+			want: []models.FieldError{{Field: "domainUrl", Rule: "domainurl"}},
+		},
+		{
+			name:   "TenantDomainTooLong",
+			method: http.MethodPost, path: "/tenants",
+			body: `{"domainUrl": "` + long + `.example.com"}`,
+			want: []models.FieldError{{Field: "domainUrl", Rule: "max", Param: "255"}},
+		},
+		{
+			name:   "BookMissingFields",
+			method: http.MethodPost, path: "/books", host: host,
+			body: `{"isbn": "978-0-306-40615-7"}`,
+			want: []models.FieldError{{Field: "name", Rule: "required"}, {Field: "author", Rule: "required"}},
+		},
+		{
+			name:   "BookNameTooLong",
+			method: http.MethodPost, path: "/books", host: host,
+			body: `{"name": "` + long + `", "author": "Frank Herbert"}`,
+			want: []models.FieldError{{Field: "name", Rule: "max", Param: "255"}},
+		},
+		{
+			name:   "UpdateMissingName",
+			method: http.MethodPut, path: "/books/1", host: host,
+			body: `{"author": "Frank Herbert", "version": 1}`,
+			want: []models.FieldError{{Field: "name", Rule: "required"}},
+		},
+		{
+			name:   "UpdateAuthorTooLong",
+			method: http.MethodPut, path: "/books/1", host: host,
+			body: `{"name": "Dune", "author": "` + long + `", "version": 1}`,
+			want: []models.FieldError{{Field: "author", Rule: "max", Param: "255"}},
+		},
+		{
+			name:   "UpdateMissingVersion",
+			method: http.MethodPut, path: "/books/1", host: host,
+			body: `{"name": "Dune"}`,
+			want: []models.FieldError{{Field: "version", Rule: "required"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(e, tt.method, tt.path, tt.host, tt.body)
+			require.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+			assert.Equal(t, models.ErrorResponse{
+				Message:  "request validation failed",
+				Category: string(CategoryValidation),
+				Fields:   tt.want,
+			}, decode[models.ErrorResponse](t, rr))
+		})
+	}
+
+	rr = serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "https://tenant2.example.com"}`)
+	assert.Equal(t, http.StatusCreated, rr.Code, "a scheme is allowed: %s", rr.Body.String())
+}
+
+func TestCreateBookValidation(t *testing.T) {
+	_, e := newSQLiteServer(t)
+	const host = "tenant1.example.com"
+	rr := serve(e, http.MethodPost, "/tenants", "", `{"domainUrl": "`+host+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	t.Run("ValidISBN13", func(t *testing.T) {
+		rr := serve(e, http.MethodPost, "/books", host, `{"name": "Book 1", "author": "Author 1", "isbn": "978-0-306-40615-7"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		assert.Equal(t, models.BookResponse{ID: 1, Name: "Book 1", Author: "Author 1", ISBN: "9780306406157", Version: 1},
+			decode[models.BookResponse](t, rr))
+
+		rr = serve(e, http.MethodGet, "/books", host, "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, []models.BookResponse{{ID: 1, Name: "Book 1", Author: "Author 1", ISBN: "9780306406157", Version: 1}},
+			decode[[]models.BookResponse](t, rr))
+	})
+	t.Run("InvalidChecksum", func(t *testing.T) {
+		rr := serve(e, http.MethodPost, "/books", host, `{"name": "Book 2", "author": "Author 2", "isbn": "978-0-306-40615-8"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		rr = serve(e, http.MethodPut, "/books/1", host, `{"name": "Book 1", "isbn": "978-0-306-40615-8", "version": 1}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+	t.Run("MissingAuthor", func(t *testing.T) {
+		rr := serve(e, http.MethodPost, "/books", host, `{"name": "Book 3", "isbn": "978-0-306-40615-7"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{"message": "request validation failed", "category": "validation", "fields": [{"field": "author", "rule": "required"}]}`, rr.Body.String())
+	})
+}
@@ -115,6 +115,7 @@ func (cr *controller) createTenantHandler(c *gin.Context) {
 	res := &models.TenantResponse{
 		ID:        tenant.ID,
 		DomainURL: tenant.DomainURL,
+		Status:    tenant.Status,
 	}
 	c.JSON(http.StatusCreated, res)
 }
@@ -172,6 +173,12 @@ func (cr *controller) createBookHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if book.ISBN != "" {
+		if book.ISBN, err = models.NormalizeISBN(book.ISBN); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 	book.TenantSchema = tenantID
 	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
 	if tenantErr != nil {
@@ -185,8 +192,11 @@ func (cr *controller) createBookHandler(c *gin.Context) {
 	}
 
 	res := &models.BookResponse{
-		ID:   book.ID,
-		Name: book.Name,
+		ID:      book.ID,
+		Name:    book.Name,
+		Author:  book.Author,
+		ISBN:    book.ISBN,
+		Version: book.Version,
 	}
 	c.JSON(http.StatusCreated, res)
 }
@@ -226,15 +236,39 @@ func (cr *controller) updateBookHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
 		return
 	}
-	book := &models.Book{}
+	if body.Version == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required"})
+		return
+	}
+	if body.ISBN != "" {
+		if body.ISBN, err = models.NormalizeISBN(body.ISBN); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	fields := body.Fields()
 	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
 	if tenantErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": tenantErr.Error()})
 		return
 	}
 	defer reset()
-	if err := cr.db.Model(book).Where("id = ?", bookID).Updates(models.Book{Name: body.Name}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	res := cr.db.Model(&models.Book{}).Where("id = ? AND version = ?", bookID, body.Version).Updates(fields)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		var n int64
+		if err := cr.db.Model(&models.Book{}).Where("id = ?", bookID).Count(&n).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if n > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "book was modified concurrently, fetch it and retry"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "book not found"})
 		return
 	}
 	c.Status(http.StatusOK)
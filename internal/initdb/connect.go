@@ -41,6 +41,15 @@ func Connect(ctx context.Context, driver string, opts ...Option) (db *multitenan
 	color.Set(color.FgYellow, color.Bold)
 	defer color.Unset()
 	log.Printf("Connecting to %q database...", driver)
+	if driver == "sqlite" {
+		db, cleanup, err = connectSQLite()
+		if err != nil {
+			return nil, cleanup, err
+		}
+		color.Set(color.FgGreen, color.Bold)
+		log.Println("Connected to database.")
+		return db, cleanup, nil
+	}
 	log.Println("This may take a few seconds...")
 	var config = struct {
 		User, Password, Name, Port string
@@ -31,6 +31,7 @@ func MakeBook(tenant *models.Tenant, id int) *models.Book {
 	return &models.Book{
 		Tenant: *tenant,
 		Name:   fmt.Sprintf("Book %d", id),
+		Author: fmt.Sprintf("Author %d", id),
 	}
 }
 
@@ -51,7 +52,7 @@ func CreateExampleData(ctx context.Context, db *multitenancy.DB, opts ...CreateE
 		defer color.Unset()
 		log.Println("Creating example data...")
 		log.Println("This may take a few seconds...")
-		if err = db.RegisterModels(ctx, &models.Tenant{}, &models.Book{}); err != nil {
+		if err = db.RegisterModels(ctx, &models.Tenant{}, &models.BookCounter{}, &models.Book{}, &models.Tag{}); err != nil {
 			return
 		}
 
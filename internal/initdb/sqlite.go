@@ -0,0 +1,260 @@
+package initdb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
+	"github.com/bartventer/gorm-multitenancy/v8/pkg/driver"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The "sqlite" driver is a DEV-ONLY, in-memory stand-in for PostgreSQL.
+//
+// SQLite has no schemas, so each tenant (and the shared "public" schema) is
+// emulated with its own in-memory database ATTACHed to the connection under
+// the schema name. This keeps schema-qualified table names such as
+// "tenant1.books", as produced by scopes.WithTenantSchema, working unchanged.
+// UseTenant is emulated by qualifying unqualified tenant tables with the
+// current tenant, which is tracked per connection pool (the database or a
+// transaction) just like search_path is connection state.
+//
+// The emulation differs from PostgreSQL in important ways: there is a single
+// connection, foreign keys across tenants are not created, and each tenant
+// schema lives only as long as the process. Never use it in production.
+
+var sqliteIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqliteFactory implements [driver.DBFactory] for the dev-only SQLite mode.
+type sqliteFactory struct {
+	prefix string // prefix makes the shared in-memory database names unique per connection.
+
+	mu      sync.RWMutex
+	models  []driver.TenantTabler
+	schemas map[string]*gorm.DB      // schemas holds a connection per attached schema, keeping it alive.
+	current map[gorm.ConnPool]string // current is the tenant set by UseTenant on each pool.
+}
+
+var _ driver.DBFactory = (*sqliteFactory)(nil)
+
+func connectSQLite() (db *multitenancy.DB, cleanup func(), err error) {
+	log.Println("WARNING: the sqlite driver emulates tenant schemas and is for local development only.")
+	var buf [8]byte
+	if _, err = rand.Read(buf[:]); err != nil {
+		return nil, nil, err
+	}
+	f := &sqliteFactory{
+		prefix:  hex.EncodeToString(buf[:]),
+		schemas: make(map[string]*gorm.DB),
+		current: make(map[gorm.ConnPool]string),
+	}
+	gdb, err := gorm.Open(sqlite.Open(f.dsn("main")), &gorm.Config{})
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	// ATTACH is per connection, so all queries must share a single one.
+	sqlDB.SetMaxOpenConns(1)
+
+	// The sqlite dialect builds INSERT from the unqualified table name,
+	// dropping the schema of tables like "tenant1.books".
+	insert := gdb.ClauseBuilders["INSERT"]
+	gdb.ClauseBuilders["INSERT"] = func(c clause.Clause, builder clause.Builder) {
+		if in, ok := c.Expression.(clause.Insert); ok && in.Table.Name == "" {
+			if stmt, ok := builder.(*gorm.Statement); ok && stmt.TableExpr != nil {
+				stmt.WriteString("INSERT ")
+				if in.Modifier != "" {
+					stmt.WriteString(in.Modifier)
+					stmt.WriteByte(' ')
+				}
+				stmt.WriteString("INTO ")
+				stmt.WriteQuoted(clause.Table{Name: clause.CurrentTable})
+				return
+			}
+		}
+		insert(c, builder)
+	}
+	cb := gdb.Callback()
+	for _, regErr := range []error{
+		cb.Create().Before("*").Register("initdb:sqlite_use_tenant", f.qualifyTenantTable),
+		cb.Query().Before("*").Register("initdb:sqlite_use_tenant", f.qualifyTenantTable),
+		cb.Update().Before("*").Register("initdb:sqlite_use_tenant", f.qualifyTenantTable),
+		cb.Delete().Before("*").Register("initdb:sqlite_use_tenant", f.qualifyTenantTable),
+		cb.Row().Before("*").Register("initdb:sqlite_use_tenant", f.qualifyTenantTable),
+	} {
+		if regErr != nil {
+			return nil, nil, regErr
+		}
+	}
+
+	cleanup = func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, conn := range f.schemas {
+			closeSQLite(conn)
+		}
+		closeSQLite(gdb)
+	}
+	return multitenancy.NewDB(f, gdb), cleanup, nil
+}
+
+func (f *sqliteFactory) dsn(schema string) string {
+	return fmt.Sprintf("file:%s_%s?mode=memory&cache=shared", f.prefix, schema)
+}
+
+func closeSQLite(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
+
+// qualifyTenantTable is a GORM callback emulating search_path: while a tenant
+// is in use, unqualified tenant tables resolve to that tenant's schema.
+func (f *sqliteFactory) qualifyTenantTable(db *gorm.DB) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	stmt := db.Statement
+	current := f.current[stmt.ConnPool]
+	if current == "" || stmt.TableExpr != nil || stmt.Table == "" {
+		return
+	}
+	for _, m := range f.models {
+		if !m.IsSharedModel() && m.TableName() == stmt.Table {
+			stmt.TableExpr = &clause.Expr{SQL: stmt.Quote(current + "." + stmt.Table)}
+			return
+		}
+	}
+}
+
+// NewDB implements [driver.DBFactory].
+func (f *sqliteFactory) NewDB(db *gorm.DB) driver.DB {
+	return &sqliteDB{f: f, db: db}
+}
+
+type sqliteDB struct {
+	f  *sqliteFactory
+	db *gorm.DB
+}
+
+var _ driver.DB = (*sqliteDB)(nil)
+
+func (s *sqliteDB) RegisterModels(ctx context.Context, models ...driver.TenantTabler) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	s.f.models = append(s.f.models, models...)
+	return nil
+}
+
+func (s *sqliteDB) MigrateSharedModels(ctx context.Context) error {
+	return s.migrate(ctx, "", true)
+}
+
+func (s *sqliteDB) MigrateTenantModels(ctx context.Context, tenantID string) error {
+	return s.migrate(ctx, tenantID, false)
+}
+
+// migrate creates the tables of the shared or tenant models. Migrations run
+// on a dedicated connection to the schema's database, where the tables are
+// unqualified, because SQLite does not accept schema-qualified tables in
+// CREATE INDEX.
+func (s *sqliteDB) migrate(ctx context.Context, tenantID string, shared bool) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	for _, m := range s.f.models {
+		if m.IsSharedModel() != shared {
+			continue
+		}
+		schema, table := tenantID, m.TableName()
+		if shared {
+			var ok bool
+			if schema, table, ok = strings.Cut(table, "."); !ok {
+				return fmt.Errorf("sqlite: shared model table %q must be schema-qualified", m.TableName())
+			}
+		}
+		conn, err := s.attach(ctx, schema)
+		if err != nil {
+			return err
+		}
+		if err = conn.WithContext(ctx).Table(table).AutoMigrate(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attach returns the connection to the schema's database, creating and
+// attaching it on first use. The caller must hold s.f.mu.
+func (s *sqliteDB) attach(ctx context.Context, schema string) (*gorm.DB, error) {
+	if conn, ok := s.f.schemas[schema]; ok {
+		return conn, nil
+	}
+	if !sqliteIdentifier.MatchString(schema) {
+		return nil, fmt.Errorf("sqlite: invalid schema name %q", schema)
+	}
+	conn, err := gorm.Open(sqlite.Open(s.f.dsn(schema)), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		IgnoreRelationshipsWhenMigrating:         true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.db.WithContext(ctx).Exec("ATTACH DATABASE ? AS ?", s.f.dsn(schema), schema).Error; err != nil {
+		closeSQLite(conn)
+		return nil, err
+	}
+	s.f.schemas[schema] = conn
+	return conn, nil
+}
+
+func (s *sqliteDB) OffboardTenant(ctx context.Context, tenantID string) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	conn, ok := s.f.schemas[tenantID]
+	if !ok {
+		return nil
+	}
+	if err := s.db.WithContext(ctx).Exec("DETACH DATABASE ?", tenantID).Error; err != nil {
+		return err
+	}
+	closeSQLite(conn)
+	delete(s.f.schemas, tenantID)
+	return nil
+}
+
+func (s *sqliteDB) UseTenant(ctx context.Context, tenantID string) (reset func() error, err error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if _, ok := s.f.schemas[tenantID]; !ok {
+		return nil, fmt.Errorf("sqlite: schema %q does not exist", tenantID)
+	}
+	pool := s.db.Statement.ConnPool
+	prev := s.f.current[pool]
+	s.f.current[pool] = tenantID
+	return func() error {
+		s.f.mu.Lock()
+		defer s.f.mu.Unlock()
+		if prev == "" {
+			delete(s.f.current, pool)
+		} else {
+			s.f.current[pool] = prev
+		}
+		return nil
+	}, nil
+}
+
+func (s *sqliteDB) CurrentTenant(ctx context.Context) string {
+	s.f.mu.RLock()
+	defer s.f.mu.RUnlock()
+	return s.f.current[s.db.Statement.ConnPool]
+}
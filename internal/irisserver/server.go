@@ -119,6 +119,7 @@ func (cr *controller) createTenantHandler(ctx iris.Context) {
 	res := &models.TenantResponse{
 		ID:        tenant.ID,
 		DomainURL: tenant.DomainURL,
+		Status:    tenant.Status,
 	}
 	ctx.StatusCode(http.StatusCreated)
 	ctx.JSON(res)
@@ -185,6 +186,13 @@ func (cr *controller) createBookHandler(ctx iris.Context) {
 		ctx.JSON(iris.Map{"error": err.Error()})
 		return
 	}
+	if book.ISBN != "" {
+		if book.ISBN, err = models.NormalizeISBN(book.ISBN); err != nil {
+			ctx.StatusCode(http.StatusBadRequest)
+			ctx.JSON(iris.Map{"error": err.Error()})
+			return
+		}
+	}
 	book.TenantSchema = tenantID
 	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
 	if tenantErr != nil {
@@ -200,8 +208,11 @@ func (cr *controller) createBookHandler(ctx iris.Context) {
 	}
 
 	res := &models.BookResponse{
-		ID:   book.ID,
-		Name: book.Name,
+		ID:      book.ID,
+		Name:    book.Name,
+		Author:  book.Author,
+		ISBN:    book.ISBN,
+		Version: book.Version,
 	}
 	ctx.StatusCode(http.StatusCreated)
 	ctx.JSON(res)
@@ -248,7 +259,19 @@ func (cr *controller) updateBookHandler(ctx iris.Context) {
 		ctx.JSON(iris.Map{"error": "name is required"})
 		return
 	}
-	book := &models.Book{}
+	if body.Version == 0 {
+		ctx.StatusCode(http.StatusBadRequest)
+		ctx.JSON(iris.Map{"error": "version is required"})
+		return
+	}
+	if body.ISBN != "" {
+		if body.ISBN, err = models.NormalizeISBN(body.ISBN); err != nil {
+			ctx.StatusCode(http.StatusBadRequest)
+			ctx.JSON(iris.Map{"error": err.Error()})
+			return
+		}
+	}
+	fields := body.Fields()
 	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
 	if tenantErr != nil {
 		ctx.StatusCode(http.StatusInternalServerError)
@@ -256,9 +279,26 @@ func (cr *controller) updateBookHandler(ctx iris.Context) {
 		return
 	}
 	defer reset()
-	if err := cr.db.Model(book).Where("id = ?", bookID).Updates(models.Book{Name: body.Name}).Error; err != nil {
+	res := cr.db.Model(&models.Book{}).Where("id = ? AND version = ?", bookID, body.Version).Updates(fields)
+	if res.Error != nil {
 		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": err.Error()})
+		ctx.JSON(iris.Map{"error": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		var n int64
+		if err := cr.db.Model(&models.Book{}).Where("id = ?", bookID).Count(&n).Error; err != nil {
+			ctx.StatusCode(http.StatusInternalServerError)
+			ctx.JSON(iris.Map{"error": err.Error()})
+			return
+		}
+		if n > 0 {
+			ctx.StatusCode(http.StatusConflict)
+			ctx.JSON(iris.Map{"error": "book was modified concurrently, fetch it and retry"})
+			return
+		}
+		ctx.StatusCode(http.StatusNotFound)
+		ctx.JSON(iris.Map{"error": "book not found"})
 		return
 	}
 	ctx.StatusCode(http.StatusOK)
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidISBN is returned by [NormalizeISBN] for an invalid ISBN.
+var ErrInvalidISBN = errors.New("isbn must be a valid ISBN-10 or ISBN-13")
+
+// NormalizeISBN validates an ISBN-10 or ISBN-13, including its check digit,
+// and returns it without hyphens or spaces. Every server checks the ISBNs of
+// the books it stores with it.
+func NormalizeISBN(isbn string) (string, error) {
+	isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+	var valid bool
+	switch len(isbn) {
+	case 10:
+		valid = validISBN10(isbn)
+	case 13:
+		valid = validISBN13(isbn)
+	}
+	if !valid {
+		return "", ErrInvalidISBN
+	}
+	return isbn, nil
+}
+
+// validISBN10 reports whether the weighted sum of the digits is divisible
+// by 11. The check digit may be X, standing for 10.
+func validISBN10(isbn string) bool {
+	sum := 0
+	for i, r := range isbn {
+		var d int
+		switch {
+		case r >= '0' && r <= '9':
+			d = int(r - '0')
+		case r == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * d
+	}
+	return sum%11 == 0
+}
+
+// validISBN13 reports whether the digits, alternately weighted 1 and 3, sum
+// to a multiple of 10.
+func validISBN13(isbn string) bool {
+	sum := 0
+	for i, r := range isbn {
+		if r < '0' || r > '9' {
+			return false
+		}
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
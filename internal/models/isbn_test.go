@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		isbn    string
+		want    string
+		wantErr bool
+	}{
+		{isbn: "978-0-306-40615-7", want: "9780306406157"},
+		{isbn: "9780306406157", want: "9780306406157"},
+		{isbn: "0-306-40615-2", want: "0306406152"},
+		{isbn: "0-8044-2957-x", want: "080442957X"},
+		{isbn: "978-0-306-40615-8", wantErr: true},
+		{isbn: "0-306-40615-3", wantErr: true},
+		{isbn: "X306406152", wantErr: true},
+		{isbn: "978030640615", wantErr: true},
+		{isbn: "97803064061a7", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.isbn, func(t *testing.T) {
+			got, err := NormalizeISBN(tt.isbn)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidISBN)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	multitenancy "github.com/bartventer/gorm-multitenancy/v8"
 	"github.com/bartventer/gorm-multitenancy/v8/pkg/driver"
 	"gorm.io/gorm"
@@ -9,6 +11,16 @@ import (
 const (
 	TableNameTenant = "public.tenants" // TableNameTenant is the table name for the tenant model.
 	TableNameBook   = "books"          // TableNameBook is the table name for the book model.
+	TableNameTag    = "tags"           // TableNameTag is the table name for the tag model.
+
+	TableNameBookCounter = "public.book_counters" // TableNameBookCounter is the table name for the book counter model.
+)
+
+// Tenant statuses.
+const (
+	TenantActive    = "active"    // TenantActive is a tenant whose schema is migrated, so it is served.
+	TenantPending   = "pending"   // TenantPending is a tenant whose schema awaits its first migration.
+	TenantSuspended = "suspended" // TenantSuspended is a tenant whose requests are refused, e.g. for non-payment.
 )
 
 type (
@@ -16,19 +28,52 @@ type (
 	Tenant struct {
 		gorm.Model
 		multitenancy.TenantModel
+		Tier string `gorm:"column:tier;size:32;not null;default:''"` // Tier is the tenant's plan, e.g. "free".
+
+		// DefaultPageSize and MaxPageSize override the server's page sizes
+		// for the tenant's lists; zero means no override.
+		DefaultPageSize int `gorm:"column:default_page_size;not null;default:0"`
+		MaxPageSize     int `gorm:"column:max_page_size;not null;default:0"`
+
+		Timezone string `gorm:"column:timezone;size:64;not null;default:''"` // Timezone is the tenant's IANA time zone, if any.
+
+		Status string `gorm:"column:status;size:16;not null;default:'active'"` // Status is [TenantActive], [TenantPending] or [TenantSuspended].
 	}
 
 	// Book is the book model.
 	Book struct {
 		gorm.Model
-		Name         string `gorm:"column:name;size:255;not null;"`
+		Name         string `gorm:"column:name;size:255;not null;" validate:"required,max=255"`
+		Author       string `gorm:"column:author;size:255;not null;default:''" validate:"required,max=255"`
+		ISBN         string `gorm:"column:isbn;size:13" validate:"omitempty,max=17"`
 		TenantSchema string `gorm:"column:tenant_schema"`
 		Tenant       Tenant `gorm:"foreignKey:TenantSchema;references:SchemaName"`
+		Tags         []Tag  `gorm:"foreignKey:BookID"`
+
+		// Version is incremented by every update, so that concurrent updates
+		// can be detected. A new book is at version 1.
+		Version uint `gorm:"column:version;not null;default:1"`
+	}
+
+	// BookCounter is the number of books of a tenant, maintained on writes so
+	// it can be read without counting.
+	BookCounter struct {
+		TenantSchema string `gorm:"column:tenant_schema;primaryKey;size:63"`
+		BookCount    int64  `gorm:"column:book_count;not null;default:0"`
+	}
+
+	// Tag is a label attached to a book.
+	Tag struct {
+		gorm.Model
+		BookID uint   `gorm:"column:book_id;not null;index"`
+		Name   string `gorm:"column:name;size:64;not null;" json:"name"`
 	}
 )
 
 var _ driver.TenantTabler = new(Tenant)
 var _ driver.TenantTabler = new(Book)
+var _ driver.TenantTabler = new(Tag)
+var _ driver.TenantTabler = new(BookCounter)
 
 func (Tenant) TableName() string   { return TableNameTenant }
 func (Tenant) IsSharedModel() bool { return true }
@@ -36,26 +81,212 @@ func (Tenant) IsSharedModel() bool { return true }
 func (Book) TableName() string   { return TableNameBook }
 func (Book) IsSharedModel() bool { return false }
 
+func (Tag) TableName() string   { return TableNameTag }
+func (Tag) IsSharedModel() bool { return false }
+
+func (BookCounter) TableName() string   { return TableNameBookCounter }
+func (BookCounter) IsSharedModel() bool { return true }
+
 type (
 	// CreateTenantBody is the request body for creating a tenant.
 	CreateTenantBody struct {
-		DomainURL string `json:"domainUrl"`
+		DomainURL string `json:"domainUrl" validate:"required,max=255,domainurl"`
+		Tier      string `json:"tier,omitempty" validate:"max=32"`
+
+		DefaultPageSize int `json:"defaultPageSize,omitempty" validate:"min=0"`
+		MaxPageSize     int `json:"maxPageSize,omitempty" validate:"min=0"`
+
+		Timezone string `json:"timezone,omitempty" validate:"omitempty,timezone"` // Timezone is an IANA time zone, e.g. "Europe/Paris".
+	}
+
+	// CreateBookBody is the request body for creating a book.
+	CreateBookBody struct {
+		Name   string          `json:"name" validate:"required,max=255"`
+		Author string          `json:"author" validate:"required,max=255"`
+		ISBN   string          `json:"isbn" validate:"omitempty,max=17"`
+		Tags   []CreateTagBody `json:"tags,omitempty" validate:"dive"`
+	}
+
+	// CreateTagBody is a tag of a book being created.
+	CreateTagBody struct {
+		Name string `json:"name" validate:"required,max=64"`
 	}
 
 	// UpdateBookBody is the request body for updating a book.
 	UpdateBookBody struct {
-		Name string `json:"name"`
+		Name   string `json:"name" validate:"required,min=1,max=255"`
+		Author string `json:"author" validate:"max=255"`
+		ISBN   string `json:"isbn" validate:"omitempty,max=17"`
+
+		// Version is the version of the book being updated, as last read.
+		// The update is refused if the book has changed since.
+		Version uint `json:"version" validate:"required"`
+	}
+
+	// BatchDeleteBooksBody is the request body for deleting books in bulk.
+	BatchDeleteBooksBody struct {
+		IDs []uint `json:"ids"`
+	}
+
+	// BatchDeleteBooksResponse is the response body for deleting books in bulk.
+	BatchDeleteBooksResponse struct {
+		Deleted int64 `json:"deleted"` // Deleted is the number of books actually deleted.
 	}
 
 	// BookResponse is the response body for a book.
 	BookResponse struct {
-		ID   uint   `json:"id"`
-		Name string `json:"name"`
+		ID      uint   `json:"id"`
+		Name    string `json:"name"`
+		Author  string `json:"author"`
+		ISBN    string `json:"isbn,omitempty"`
+		Version uint   `json:"version,omitempty"`
+	}
+
+	// FlatBookResponse is a denormalized row of the flat book view, with one
+	// row per tag of a book.
+	FlatBookResponse struct {
+		BookID uint   `json:"bookId"`
+		Name   string `json:"name"`
+		Author string `json:"author"`
+		ISBN   string `json:"isbn,omitempty"`
+		Tag    string `json:"tag,omitempty"`
+	}
+
+	// BookCountResponse is the response body for a tenant's book count.
+	BookCountResponse struct {
+		Count int64 `json:"count"`
+	}
+
+	// BookStatsResponse is the response body for counting every tenant's
+	// books.
+	BookStatsResponse struct {
+		Books  map[string]int64  `json:"books"`            // Books maps the schemas counted to their number of books.
+		Total  int64             `json:"total"`            // Total is the number of books of the tenants counted.
+		Failed map[string]string `json:"failed,omitempty"` // Failed maps the schemas that could not be counted to the reason.
+	}
+
+	// AvailabilityResponse is the response body for checking whether a
+	// subdomain is available for a new tenant.
+	AvailabilityResponse struct {
+		Subdomain string `json:"subdomain,omitempty"`
+		Available bool   `json:"available"`
+		Reason    string `json:"reason,omitempty"` // Reason is why the subdomain is unavailable.
 	}
 
 	// TenantResponse is the response body for a tenant.
 	TenantResponse struct {
 		ID        uint   `json:"id"`
 		DomainURL string `json:"domainUrl"`
+		Tier      string `json:"tier,omitempty"`
+
+		DefaultPageSize int `json:"defaultPageSize,omitempty"`
+		MaxPageSize     int `json:"maxPageSize,omitempty"`
+
+		Timezone string `json:"timezone,omitempty"`
+
+		Status string `json:"status,omitempty"`
+	}
+
+	// MigrateTenantsResponse is the response body for migrating all tenants.
+	MigrateTenantsResponse struct {
+		Migrated []string          `json:"migrated"`         // Migrated lists the schemas migrated successfully.
+		Failed   map[string]string `json:"failed,omitempty"` // Failed maps the schemas that failed to the reason.
+	}
+
+	// MigrationProgress is a record of the streamed migration of all tenants,
+	// sent as each tenant's migration completes.
+	MigrationProgress struct {
+		Tenant string `json:"tenant"`
+		Status string `json:"status"` // Status is "migrated" or "failed".
+		Error  string `json:"error,omitempty"`
+		Done   int    `json:"done"`  // Done is the number of tenants processed so far, this one included.
+		Total  int    `json:"total"` // Total is the number of tenants being migrated.
+	}
+
+	// ReconcileResponse is the response body for reconciling tenant rows
+	// with tenant schemas.
+	ReconcileResponse struct {
+		Applied         bool              `json:"applied"`          // Applied reports whether fixes were applied.
+		OrphanedSchemas []string          `json:"orphanedSchemas"`  // OrphanedSchemas are schemas without a tenant row.
+		MissingSchemas  []string          `json:"missingSchemas"`   // MissingSchemas are tenant rows without a schema.
+		Dropped         []string          `json:"dropped"`          // Dropped lists the orphaned schemas dropped.
+		Failed          map[string]string `json:"failed,omitempty"` // Failed maps the schemas that could not be dropped to the reason.
+	}
+
+	// ExportTenantResponse is the response body for exporting a tenant.
+	ExportTenantResponse struct {
+		Schema     string         `json:"schema"`
+		ExportedAt time.Time      `json:"exportedAt"`
+		Rows       map[string]int `json:"rows"` // Rows maps the exported tables to their number of rows.
+	}
+
+	// OffboardPreviewResponse is the response body for a dry-run of deleting
+	// a tenant, describing what deleting it would drop.
+	OffboardPreviewResponse struct {
+		Schema string         `json:"schema"`
+		Tables []string       `json:"tables"`
+		Rows   map[string]int `json:"rows"` // Rows maps the tables to their number of rows, deleted ones included.
+	}
+
+	// JobResponse is the response body for a background job.
+	JobResponse struct {
+		ID         string     `json:"id"`
+		Kind       string     `json:"kind"`
+		Tenant     string     `json:"tenant"`
+		Status     string     `json:"status"`
+		Error      string     `json:"error,omitempty"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	}
+
+	// JobEvent is a step of a background job's progress, streamed by the job
+	// logs endpoint.
+	JobEvent struct {
+		Time    time.Time `json:"time"`
+		Message string    `json:"message"`
+		Error   string    `json:"error,omitempty"`
+	}
+
+	// TimeResponse is the response body for the server's clock.
+	TimeResponse struct {
+		Time           time.Time `json:"time"`                     // Time is the server's current time, in UTC.
+		Timezone       string    `json:"timezone"`                 // Timezone is the server's configured time zone.
+		Tenant         string    `json:"tenant,omitempty"`         // Tenant is the tenant named by the request host, if it has a time zone.
+		TenantTimezone string    `json:"tenantTimezone,omitempty"` // TenantTimezone is the tenant's time zone.
+	}
+
+	// HealthResponse is the response body of the health probes.
+	HealthResponse struct {
+		Status string `json:"status"` // Status is "ok" or "unavailable".
+	}
+
+	// ErrorResponse is the response body for an error.
+	ErrorResponse struct {
+		Message  string       `json:"message"`
+		Category string       `json:"category,omitempty"`
+		Fields   []FieldError `json:"fields,omitempty"` // Fields lists the fields failing validation.
+	}
+
+	// FieldError is a request body field failing validation.
+	FieldError struct {
+		Field string `json:"field"`
+		Rule  string `json:"rule"`            // Rule is the failed rule, e.g. "required" or "max".
+		Param string `json:"param,omitempty"` // Param is the rule's parameter, e.g. the maximum length.
 	}
 )
+
+// Fields returns the columns of a book to update from the body, leaving
+// those of empty fields unchanged, and incrementing the version. The update
+// must be made where the book is still at [UpdateBookBody.Version], in the
+// same statement, so that of two updates made from the same version only
+// the first applies.
+func (b UpdateBookBody) Fields() map[string]any {
+	fields := map[string]any{"name": b.Name, "version": gorm.Expr("version + 1")}
+	if b.Author != "" {
+		fields["author"] = b.Author
+	}
+	if b.ISBN != "" {
+		fields["isbn"] = b.ISBN
+	}
+	return fields
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUpdateBookBodyFields(t *testing.T) {
+	version := gorm.Expr("version + 1")
+	assert.Equal(t, map[string]any{"name": "Dune", "version": version},
+		UpdateBookBody{Name: "Dune", Version: 3}.Fields(), "empty fields are left unchanged")
+	assert.Equal(t, map[string]any{"name": "Dune", "author": "Frank Herbert", "isbn": "9780441013593", "version": version},
+		UpdateBookBody{Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Version: 3}.Fields())
+}
@@ -120,6 +120,7 @@ func (cr *controller) createTenantHandler(w http.ResponseWriter, r *http.Request
 	res := &models.TenantResponse{
 		ID:        tenant.ID,
 		DomainURL: tenant.DomainURL,
+		Status:    tenant.Status,
 	}
 	if err = json.NewEncoder(w).Encode(res); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -189,6 +190,12 @@ func (cr *controller) createBookHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if book.ISBN != "" {
+		if book.ISBN, err = models.NormalizeISBN(book.ISBN); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 	book.TenantSchema = tenantID
 
 	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
@@ -202,8 +209,11 @@ func (cr *controller) createBookHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	res := &models.BookResponse{
-		ID:   book.ID,
-		Name: book.Name,
+		ID:      book.ID,
+		Name:    book.Name,
+		Author:  book.Author,
+		ISBN:    book.ISBN,
+		Version: book.Version,
 	}
 	w.WriteHeader(http.StatusCreated)
 	if err = json.NewEncoder(w).Encode(res); err != nil {
@@ -248,18 +258,40 @@ func (cr *controller) updateBookHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "name is required", http.StatusBadRequest)
 		return
 	}
+	if body.Version == 0 {
+		http.Error(w, "version is required", http.StatusBadRequest)
+		return
+	}
+	if body.ISBN != "" {
+		if body.ISBN, err = models.NormalizeISBN(body.ISBN); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	var book models.Book
+	fields := body.Fields()
 	reset, tenantErr := cr.db.UseTenant(context.Background(), tenantID)
 	if tenantErr != nil {
 		http.Error(w, tenantErr.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer reset()
-	if err = cr.db.Model(&book).Where("id = ?", bookID).Updates(models.Book{
-		Name: body.Name,
-	}).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	res := cr.db.Model(&models.Book{}).Where("id = ? AND version = ?", bookID, body.Version).Updates(fields)
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		var n int64
+		if err = cr.db.Model(&models.Book{}).Where("id = ?", bookID).Count(&n).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n > 0 {
+			http.Error(w, "book was modified concurrently, fetch it and retry", http.StatusConflict)
+			return
+		}
+		http.Error(w, "book not found", http.StatusNotFound)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -51,7 +51,7 @@ func RunConformance(t *testing.T, harness Harness) {
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusCreated, rr.Code)
-		assert.JSONEq(t, `{"id": 3, "domainUrl": "tenant3.example.com"}`, rr.Body.String())
+		assert.JSONEq(t, `{"id": 3, "domainUrl": "tenant3.example.com", "status": "active"}`, rr.Body.String())
 	})
 
 	t.Run("GetTenant", func(t *testing.T) {
@@ -62,11 +62,11 @@ func RunConformance(t *testing.T, harness Harness) {
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"id": 3, "domainUrl": "tenant3.example.com"}`, rr.Body.String())
+		assert.JSONEq(t, `{"id": 3, "domainUrl": "tenant3.example.com", "status": "active"}`, rr.Body.String())
 	})
 
 	t.Run("DeleteTenant", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodDelete, "/tenants/3", nil)
+		req, err := http.NewRequest(http.MethodDelete, "/tenants/3?confirm=true", nil)
 		require.NoError(t, err)
 
 		rr := httptest.NewRecorder()
@@ -89,8 +89,11 @@ func RunConformance(t *testing.T, harness Harness) {
 			book := initdb.MakeBook(tenant, i+1)
 			book.ID = uint(i + 1)
 			expectedBooks[i] = &models.BookResponse{
-				ID:   book.ID,
-				Name: book.Name,
+				ID:      book.ID,
+				Name:    book.Name,
+				Author:  book.Author,
+				ISBN:    book.ISBN,
+				Version: 1,
 			}
 		}
 
@@ -99,7 +102,7 @@ func RunConformance(t *testing.T, harness Harness) {
 	})
 
 	t.Run("CreateBook", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"name": "tenant1 - New Book"}`))
+		req, err := http.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"name": "tenant1 - New Book", "author": "Author 6", "isbn": "9780306406157"}`))
 		require.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 		req.Host = "tenant1.example.com"
@@ -108,7 +111,19 @@ func RunConformance(t *testing.T, harness Harness) {
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusCreated, rr.Code)
-		assert.JSONEq(t, `{"id": 6, "name": "tenant1 - New Book"}`, rr.Body.String())
+		assert.JSONEq(t, `{"id": 6, "name": "tenant1 - New Book", "author": "Author 6", "isbn": "9780306406157", "version": 1}`, rr.Body.String())
+	})
+
+	t.Run("CreateBookInvalidISBN", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"name": "tenant1 - Bad Book", "author": "Author 7", "isbn": "9780306406158"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Host = "tenant1.example.com"
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
 	})
 
 	t.Run("DeleteBook", func(t *testing.T) {
@@ -123,7 +138,7 @@ func RunConformance(t *testing.T, harness Harness) {
 	})
 
 	t.Run("UpdateBook", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodPut, "/books/2", strings.NewReader(`{"name": "tenant1 - Book 2 - Updated"}`))
+		req, err := http.NewRequest(http.MethodPut, "/books/2", strings.NewReader(`{"name": "tenant1 - Book 2 - Updated", "version": 1}`))
 		require.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
 		req.Host = "tenant1.example.com"
@@ -133,6 +148,18 @@ func RunConformance(t *testing.T, harness Harness) {
 
 		assert.Equal(t, http.StatusOK, rr.Code)
 	})
+
+	t.Run("UpdateBookStale", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/books/2", strings.NewReader(`{"name": "tenant1 - Book 2 - Stale", "version": 1}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Host = "tenant1.example.com"
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
 }
 
 func toJSON(t *testing.T, v interface{}) string {
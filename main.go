@@ -21,7 +21,7 @@ import (
 
 type options struct {
 	server string // server is the http server. Default is "echo". Options are "echo" and "nethttp".
-	driver string // driver is the database driver. Default is "postgres". Options are "postgres" and "mysql".
+	driver string // driver is the database driver. Default is "postgres". Options are "postgres", "mysql" and "sqlite" (development only).
 }
 
 var opts options
@@ -31,7 +31,7 @@ func (o *options) validate() error {
 	if !slices.Contains(validServers, o.server) {
 		return fmt.Errorf("invalid server: %s", o.server)
 	}
-	validDrivers := []string{"postgres", "mysql"}
+	validDrivers := []string{"postgres", "mysql", "sqlite"}
 	if !slices.Contains(validDrivers, o.driver) {
 		return fmt.Errorf("invalid driver: %s", o.driver)
 	}
@@ -50,7 +50,7 @@ func main() {
 	- Documentation & Guides: https://pkg.go.dev/github.com/bartventer/gorm-multitenancy/v8
 	`)
 
-	flag.StringVar(&opts.driver, "driver", "postgres", "Specifies the database driver to use. Options: 'postgres', 'mysql'.")
+	flag.StringVar(&opts.driver, "driver", "postgres", "Specifies the database driver to use. Options: 'postgres', 'mysql', 'sqlite' (in-memory, development only).")
 	flag.StringVar(&opts.server, "server", "echo", "Specifies the HTTP server to run and the gorm-multitenancy middleware to use. Options: 'echo', 'gin', 'iris', 'nethttp'.")
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
@@ -60,9 +60,10 @@ Examples:
 
   %s -server=echo -driver=postgres
   %s -server=nethttp -driver=mysql
+  %s -server=echo -driver=sqlite
 
 Note: The server and driver flags are optional. When not specified, the default values are used.
-`, os.Args[0], os.Args[0])
+`, os.Args[0], os.Args[0], os.Args[0])
 		os.Exit(2)
 	}
 	flag.Parse()
@@ -102,7 +103,10 @@ Note: The server and driver flags are optional. When not specified, the default
 
 	switch opts.server {
 	case "echo":
-		err = echoserver.Start(ctx, db)
+		var cfg echoserver.Config
+		if cfg, err = echoserver.LoadConfigFromEnv(); err == nil {
+			err = echoserver.Start(ctx, db, cfg.Options()...)
+		}
 	case "gin":
 		err = ginserver.Start(ctx, db)
 	case "iris":